@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"unifriend-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// The admin handlers run behind AdminMiddleware, which guarantees user_id is
+// set and belongs to an active admin.
+
+type SearchUsersQuery struct {
+	Query  string `form:"q"`
+	Status *int   `form:"status" binding:"omitempty,oneof=0 1 2"`
+	Before uint   `form:"before"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type SuspendUserInput struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type GetReportsQuery struct {
+	Status     string `form:"status" binding:"omitempty,oneof=open resolved dismissed"`
+	ReportedID uint   `form:"reported_id"`
+	Before     uint   `form:"before"`
+	Limit      int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ReviewReportInput struct {
+	Status     string `json:"status" binding:"required,oneof=resolved dismissed"`
+	Resolution string `json:"resolution" binding:"max=2000"`
+}
+
+type GetAuditLogsQuery struct {
+	AdminID    uint   `form:"admin_id"`
+	Action     string `form:"action"`
+	TargetType string `form:"target_type"`
+	TargetID   uint   `form:"target_id"`
+	Before     uint   `form:"before"`
+	Limit      int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+func SearchUsers(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	var query SearchUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, hasMore, err := models.SearchUsers(adminID, models.AdminUserFilter{
+		Query:  query.Query,
+		Status: query.Status,
+		Before: query.Before,
+		Limit:  query.Limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": users, "has_more": hasMore})
+}
+
+func GetAdminUser(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	user, err := models.GetUserForAdmin(adminID, uint(userID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func SuspendUser(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var input SuspendUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if uint(userID) == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can not suspend yourself"})
+		return
+	}
+
+	if _, err := models.GetAdminUser(uint(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = models.SuspendUser(adminID, uint(userID), input.Reason)
+	if err == models.ErrUserNotActive {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+		return
+	}
+
+	if hub != nil {
+		hub.Disconnect(uint(userID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User suspended"})
+}
+
+func ReactivateUser(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if _, err := models.GetAdminUser(uint(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = models.ReactivateUser(adminID, uint(userID))
+	if err == models.ErrUserNotSuspended {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User reactivated"})
+}
+
+func GetAdminUserImages(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if _, err := models.GetAdminUser(uint(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	images, err := models.GetUserImagesForAdmin(adminID, uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve images"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": images})
+}
+
+func GetReports(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	var query GetReportsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reports, hasMore, err := models.GetReports(adminID, models.ReportFilter{
+		Status:     query.Status,
+		ReportedID: query.ReportedID,
+		Before:     query.Before,
+		Limit:      query.Limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reports, "has_more": hasMore})
+}
+
+func GetReport(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	reportID, err := strconv.ParseUint(c.Param("report_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID format"})
+		return
+	}
+
+	report, err := models.GetReport(adminID, uint(reportID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+func ReviewReport(c *gin.Context) {
+	adminID := c.MustGet("user_id").(uint)
+
+	reportID, err := strconv.ParseUint(c.Param("report_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID format"})
+		return
+	}
+
+	var input ReviewReportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = models.ReviewReport(adminID, uint(reportID), input.Status, input.Resolution)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	if err == models.ErrReportAlreadyReviewed {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report reviewed"})
+}
+
+func GetAuditLogs(c *gin.Context) {
+	var query GetAuditLogsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	auditLogs, hasMore, err := models.GetAuditLogs(models.AuditLogFilter{
+		AdminID:    query.AdminID,
+		Action:     query.Action,
+		TargetType: query.TargetType,
+		TargetID:   query.TargetID,
+		Before:     query.Before,
+		Limit:      query.Limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": auditLogs, "has_more": hasMore})
+}
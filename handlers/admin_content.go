@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"unifriend-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MajorInput struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type EmailDomainInput struct {
+	Institution string `json:"institution" binding:"required,max=255"`
+	Domain      string `json:"domain" binding:"required,fqdn,max=255"`
+}
+
+type QuizInput struct {
+	Title       string `json:"title" binding:"required,max=255"`
+	Description string `json:"description" binding:"required,max=255"`
+}
+
+type QuestionInput struct {
+	Text    string   `json:"text" binding:"required,max=255"`
+	Options []string `json:"options" binding:"dive,required,max=255"`
+}
+
+type UpdateQuestionInput struct {
+	Text string `json:"text" binding:"required,max=255"`
+}
+
+type OptionInput struct {
+	Text string `json:"text" binding:"required,max=255"`
+}
+
+type ReorderQuestionsInput struct {
+	QuestionIDs []uint `json:"question_ids" binding:"required,min=1"`
+}
+
+type ReorderOptionsInput struct {
+	OptionIDs []uint `json:"option_ids" binding:"required,min=1"`
+}
+
+func CreateMajor(c *gin.Context) {
+	var input MajorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	major, err := models.CreateMajor(c.MustGet("user_id").(uint), input.Name)
+	if err != nil {
+		respondContentError(c, err, "Major not found", "Failed to create major")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": major})
+}
+
+func UpdateMajor(c *gin.Context) {
+	majorID, err := strconv.ParseUint(c.Param("major_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid major ID format"})
+		return
+	}
+
+	var input MajorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	major, err := models.UpdateMajor(c.MustGet("user_id").(uint), uint(majorID), input.Name)
+	if err != nil {
+		respondContentError(c, err, "Major not found", "Failed to update major")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": major})
+}
+
+func DeleteMajor(c *gin.Context) {
+	majorID, err := strconv.ParseUint(c.Param("major_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid major ID format"})
+		return
+	}
+
+	if err := models.DeleteMajor(c.MustGet("user_id").(uint), uint(majorID)); err != nil {
+		respondContentError(c, err, "Major not found", "Failed to delete major")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetEmailDomains(c *gin.Context) {
+	emailDomains, err := models.GetEmailDomains()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve email domains"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": emailDomains})
+}
+
+func CreateEmailDomain(c *gin.Context) {
+	var input EmailDomainInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	emailDomain, err := models.CreateEmailDomain(c.MustGet("user_id").(uint), input.Institution, input.Domain)
+	if err != nil {
+		respondContentError(c, err, "Email domain not found", "Failed to create email domain")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": emailDomain})
+}
+
+func UpdateEmailDomain(c *gin.Context) {
+	emailDomainID, err := strconv.ParseUint(c.Param("domain_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email domain ID format"})
+		return
+	}
+
+	var input EmailDomainInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	emailDomain, err := models.UpdateEmailDomain(c.MustGet("user_id").(uint), uint(emailDomainID), input.Institution, input.Domain)
+	if err != nil {
+		respondContentError(c, err, "Email domain not found", "Failed to update email domain")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": emailDomain})
+}
+
+func DeleteEmailDomain(c *gin.Context) {
+	emailDomainID, err := strconv.ParseUint(c.Param("domain_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email domain ID format"})
+		return
+	}
+
+	if err := models.DeleteEmailDomain(c.MustGet("user_id").(uint), uint(emailDomainID)); err != nil {
+		respondContentError(c, err, "Email domain not found", "Failed to delete email domain")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetAdminQuizzes(c *gin.Context) {
+	quizzes, err := models.GetQuizzes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve quizzes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quizzes})
+}
+
+func GetAdminQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	quiz, err := models.GetQuizWithQuestions(uint(quizID))
+	if err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to retrieve quiz")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quiz})
+}
+
+func CreateQuiz(c *gin.Context) {
+	var input QuizInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quiz, err := models.CreateQuiz(c.MustGet("user_id").(uint), input.Title, input.Description)
+	if err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to create quiz")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": quiz})
+}
+
+func UpdateQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	var input QuizInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quiz, err := models.UpdateQuiz(c.MustGet("user_id").(uint), uint(quizID), input.Title, input.Description)
+	if err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to update quiz")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quiz})
+}
+
+func DeleteQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	if err := models.DeleteQuiz(c.MustGet("user_id").(uint), uint(quizID)); err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to delete quiz")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func PublishQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	quiz, err := models.PublishQuiz(c.MustGet("user_id").(uint), uint(quizID))
+	if err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to publish quiz")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quiz})
+}
+
+func UnpublishQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	quiz, err := models.UnpublishQuiz(c.MustGet("user_id").(uint), uint(quizID))
+	if err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to unpublish quiz")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quiz})
+}
+
+func ReorderQuestions(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	var input ReorderQuestionsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.ReorderQuestions(c.MustGet("user_id").(uint), uint(quizID), input.QuestionIDs); err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to reorder questions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Questions reordered"})
+}
+
+func CreateQuestion(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	var input QuestionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	question, err := models.CreateQuestion(c.MustGet("user_id").(uint), uint(quizID), input.Text, input.Options)
+	if err != nil {
+		respondContentError(c, err, "Quiz not found", "Failed to create question")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": question})
+}
+
+func UpdateQuestion(c *gin.Context) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID format"})
+		return
+	}
+
+	var input UpdateQuestionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	question, err := models.UpdateQuestion(c.MustGet("user_id").(uint), uint(questionID), input.Text)
+	if err != nil {
+		respondContentError(c, err, "Question not found", "Failed to update question")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": question})
+}
+
+func DeleteQuestion(c *gin.Context) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID format"})
+		return
+	}
+
+	if err := models.DeleteQuestion(c.MustGet("user_id").(uint), uint(questionID)); err != nil {
+		respondContentError(c, err, "Question not found", "Failed to delete question")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func CreateOption(c *gin.Context) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID format"})
+		return
+	}
+
+	var input OptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	option, err := models.CreateOption(c.MustGet("user_id").(uint), uint(questionID), input.Text)
+	if err != nil {
+		respondContentError(c, err, "Question not found", "Failed to create option")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": option})
+}
+
+func UpdateOption(c *gin.Context) {
+	optionID, err := strconv.ParseUint(c.Param("option_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid option ID format"})
+		return
+	}
+
+	var input OptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	option, err := models.UpdateOption(c.MustGet("user_id").(uint), uint(optionID), input.Text)
+	if err != nil {
+		respondContentError(c, err, "Option not found", "Failed to update option")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": option})
+}
+
+func DeleteOption(c *gin.Context) {
+	optionID, err := strconv.ParseUint(c.Param("option_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid option ID format"})
+		return
+	}
+
+	if err := models.DeleteOption(c.MustGet("user_id").(uint), uint(optionID)); err != nil {
+		respondContentError(c, err, "Option not found", "Failed to delete option")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func ReorderOptions(c *gin.Context) {
+	questionID, err := strconv.ParseUint(c.Param("question_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID format"})
+		return
+	}
+
+	var input ReorderOptionsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.ReorderOptions(c.MustGet("user_id").(uint), uint(questionID), input.OptionIDs); err != nil {
+		respondContentError(c, err, "Question not found", "Failed to reorder options")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Options reordered"})
+}
+
+// respondContentError maps the errors of the content management models to a
+// status: missing rows are 404, conflicts with existing data are 409 and an
+// incomplete quiz is 422 with the list of problems.
+func respondContentError(c *gin.Context, err error, notFound string, failed string) {
+	var validationErr *models.QuizValidationError
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Quiz is incomplete", "problems": validationErr.Problems})
+	case err == models.ErrInvalidContentOrder:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err == models.ErrMajorExists, err == models.ErrMajorInUse, err == models.ErrEmailDomainExists,
+		err == models.ErrQuizTitleTaken, err == models.ErrQuizAnswered,
+		err == models.ErrQuizPublished, err == models.ErrQuizNotPublished:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failed})
+	}
+}
@@ -1,16 +1,24 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"log"
+	"math/big"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 	"unifriend-api/models"
+	"unifriend-api/services"
 	"unifriend-api/utils/token"
 
 	"github.com/gin-gonic/gin"
 )
 
+const PasswordResetSubject = "Unifriends redefinição de senha"
+const PasswordResetMessage = "Seu código para redefinir a senha é: "
+const PasswordResetRequestedMessage = "if the email is registered, a reset code was sent"
+
 type RegisterInput struct {
 	Password          string   `json:"password" binding:"required"`
 	RePassword        string   `json:"re_password" binding:"required"`
@@ -25,6 +33,17 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordInput struct {
+	Email      string `json:"email" binding:"required"`
+	ResetCode  int    `json:"reset_code" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	RePassword string `json:"re_password" binding:"required"`
+}
+
 type RegisterResponse struct {
 	Message string `json:"message" example:"User created successfully"`
 }
@@ -61,7 +80,11 @@ func Register(c *gin.Context) {
 
 	imagesUrl := []models.UsersImages{}
 
-	user.Password = input.Password
+	if err := user.SetPassword(input.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
 	user.Email = input.Email
 	user.Name = input.Name
 	user.MajorID = input.MajorID
@@ -77,26 +100,11 @@ func Register(c *gin.Context) {
 
 	models.DB.Preload("Major").First(&user, user.ID)
 
-	token, err := token.GenerateToken(user.ID)
-
-	if err != nil {
+	if err := startSession(c, user.ID); err != nil {
 		c.JSON(http.StatusCreated, gin.H{"error" : "something went wrong"})
 		return
 	}
 
-	tokenLifespanStr := os.Getenv("TOKEN_HOUR_LIFESPAN")
-	tokenLifespan, _ := strconv.Atoi(tokenLifespanStr)
-
-	c.SetCookie(
-		"auth_token",
-		token,
-		tokenLifespan*3600,
-		"/",
-		os.Getenv("CLIENT_DOMAIN"),
-		os.Getenv("GIN_MODE") == "release",
-		true,
-	)
-
 	response := UserLoginRegisterResponse{
 		UserID:            user.ID,
 		Name:              user.Name,
@@ -119,25 +127,17 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, user := models.LoginCheck(input.Email, input.Password)
+	user, err := models.LoginCheck(input.Email, input.Password)
 
-	if user.ID == 0 {
+	if err != nil || user.ID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "username or password is incorrect."})
 		return
 	}
 
-	tokenLifespanStr := os.Getenv("TOKEN_HOUR_LIFESPAN")
-	tokenLifespan, _ := strconv.Atoi(tokenLifespanStr)
-
-	c.SetCookie(
-		"auth_token",
-		token,
-		tokenLifespan*3600,
-		"/",
-		os.Getenv("CLIENT_DOMAIN"),
-		os.Getenv("GIN_MODE") == "release",
-		true,
-	)
+	if err := startSession(c, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
 
 	response := UserLoginRegisterResponse{
 		UserID:            user.ID,
@@ -152,16 +152,213 @@ func Login(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"error" : false, "data" : response})
 }
 
+func RefreshToken(c *gin.Context) {
+	refreshToken, err := c.Cookie("refresh_token")
+	if refreshToken == "" || err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	session, newRefreshToken, err := models.RotateSession(refreshToken)
+	if err != nil {
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	accessToken, err := token.GenerateToken(session.UserID, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	setAuthCookies(c, accessToken, newRefreshToken)
+	c.Status(http.StatusNoContent)
+}
+
 func Logout(c *gin.Context) {
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Path:     "/",
-		Domain:   os.Getenv("CLIENT_DOMAIN"),
-		Expires:  time.Unix(0, 0),
-		Secure:   os.Getenv("GIN_MODE") == "release",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	userIDUint, _ := userID.(uint)
+	sessionIDUint, _ := sessionID.(uint)
+
+	if err := models.RevokeSession(sessionIDUint, userIDUint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.Status(http.StatusNoContent)
+}
+
+func LogoutEverywhere(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	if err := models.RevokeUserSessions(userIDUint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	clearAuthCookies(c)
 	c.Status(http.StatusNoContent)
-}
\ No newline at end of file
+}
+
+func ForgotPassword(c *gin.Context, emailSender services.SesSender) {
+	var input ForgotPasswordInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return
+	}
+
+	user, err := models.GetActiveUserByEmail(input.Email)
+	if err != nil || models.HasValidPasswordResetCode(user.Email) {
+		c.JSON(http.StatusOK, gin.H{"message": PasswordResetRequestedMessage})
+		return
+	}
+
+	resetCode, err := newSecurityCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	// The code is saved first so a delivered code always works.
+	passwordReset, err := models.SavePasswordResetCode(user.Email, resetCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	if err := emailSender.SendVerificationEmail(user.Email, PasswordResetSubject, PasswordResetMessage+strconv.Itoa(resetCode)); err != nil {
+		if err := passwordReset.Discard(); err != nil {
+			log.Printf("error discarding undelivered password reset code: %v", err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": PasswordResetRequestedMessage})
+}
+
+// newSecurityCode returns a six digit code from crypto/rand. The codes grant
+// access to an account, so they must not be predictable.
+func newSecurityCode() (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(900000))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n.Int64()) + 100000, nil
+}
+
+func ResetPassword(c *gin.Context) {
+	var input ResetPasswordInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Password != input.RePassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password and re_password are not the same"})
+		return
+	}
+
+	if !isValidPassword(input.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters long, contain at least one uppercase letter, and one special symbol"})
+		return
+	}
+
+	user, err := models.GetActiveUserByEmail(input.Email)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "code expired or is incorrect"})
+		return
+	}
+
+	passwordReset, err := models.GetLatestPasswordResetCode(user.Email)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "code expired or is incorrect"})
+		return
+	}
+
+	if !passwordReset.IsValid(input.ResetCode) {
+		if err := passwordReset.RegisterFailedAttempt(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "code expired or is incorrect"})
+		return
+	}
+
+	if err := models.ResetUserPassword(&user, input.Password, passwordReset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "password updated successfully"})
+}
+
+func startSession(c *gin.Context, userID uint) error {
+	session, refreshToken, err := models.CreateSession(userID)
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := token.GenerateToken(userID, session.ID)
+	if err != nil {
+		return err
+	}
+
+	setAuthCookies(c, accessToken, refreshToken)
+	return nil
+}
+
+func setAuthCookies(c *gin.Context, accessToken string, refreshToken string) {
+	c.SetCookie(
+		"auth_token",
+		accessToken,
+		int(token.TokenLifespan().Seconds()),
+		"/",
+		os.Getenv("CLIENT_DOMAIN"),
+		os.Getenv("GIN_MODE") == "release",
+		true,
+	)
+
+	c.SetCookie(
+		"refresh_token",
+		refreshToken,
+		int(token.RefreshTokenLifespan().Seconds()),
+		"/",
+		os.Getenv("CLIENT_DOMAIN"),
+		os.Getenv("GIN_MODE") == "release",
+		true,
+	)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	for _, name := range []string{"auth_token", "refresh_token"} {
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Domain:   os.Getenv("CLIENT_DOMAIN"),
+			Expires:  time.Unix(0, 0),
+			Secure:   os.Getenv("GIN_MODE") == "release",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"unifriend-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportUserInput struct {
+	Reason     string `json:"reason" binding:"required"`
+	Details    string `json:"details" binding:"max=2000"`
+	MessageIDs []uint `json:"message_ids" binding:"max=20"`
+}
+
+// BlockUser also removes the connection between the two users, including
+// their messages. Clients that want to report the conversation must do it
+// before blocking.
+func BlockUser(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	blockedUserID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	if uint(blockedUserID) == userIDUint {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can not block yourself"})
+		return
+	}
+
+	if _, err := models.GetUserByID(uint(blockedUserID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := models.BlockUser(userIDUint, uint(blockedUserID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	if hub != nil {
+		hub.Block(userIDUint, uint(blockedUserID))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User blocked"})
+}
+
+func UnblockUser(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	blockedUserID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	unblocked, err := models.UnblockUser(userIDUint, uint(blockedUserID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+
+	if !unblocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+		return
+	}
+
+	// The other user may have blocked this one too, that block still holds.
+	if hub != nil && !models.IsBlocked(userIDUint, uint(blockedUserID)) {
+		hub.Unblock(userIDUint, uint(blockedUserID))
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetBlockedUsers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	blockedUsers, err := models.GetBlockedUsers(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": blockedUsers})
+}
+
+func ReportUser(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	reportedUserID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var input ReportUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.ValidReportReason(input.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report reason"})
+		return
+	}
+
+	if uint(reportedUserID) == userIDUint {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can not report yourself"})
+		return
+	}
+
+	if _, err := models.GetUserByID(uint(reportedUserID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	report, err := models.CreateReport(userIDUint, uint(reportedUserID), input.Reason, input.Details, input.MessageIDs)
+	if err == models.ErrReportedMessageNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report user"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": report})
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 	"unifriend-api/models"
+	"unifriend-api/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -60,6 +61,14 @@ func CreateConnectionRequest (c *gin.Context) {
 		return
 	}
 
+	notifyUser(requestedUserIdUint32, models.NotificationConnectionRequestReceived, requestingUserIdUint, models.NotificationTargetConnectionRequest, connectionRequest.ID)
+
+	if hub != nil {
+		if requestingUser, err := models.GetUserByID(requestingUserIdUint); err == nil {
+			hub.NotifyOffline(requestedUserIdUint32, services.NewConnectionRequestPush(&connectionRequest, requestingUser.Name))
+		}
+	}
+
 	c.JSON(http.StatusCreated, gin.H{"data": connectionRequest})
 }
 
@@ -112,6 +121,14 @@ func AcceptConnectionRequest(c *gin.Context) {
 		return
 	}
 
+	notifyUser(connection.UserAID, models.NotificationConnectionRequestAccepted, userIDUint, models.NotificationTargetConnection, connection.ID)
+
+	if hub != nil {
+		if acceptingUser, err := models.GetUserByID(userIDUint); err == nil {
+			hub.NotifyOffline(connection.UserAID, services.NewConnectionAcceptedPush(&connection, acceptingUser.Name))
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":    "Connection request accepted",
 	})
@@ -186,6 +203,18 @@ func GetConnections(c *gin.Context) {
 		return
 	}
 
+	if hub != nil {
+		userIDs := make([]uint, len(connectionRequests))
+		for i := range connectionRequests {
+			userIDs[i] = connectionRequests[i].UserID
+		}
+
+		online := hub.OnlineUsers(userIDs)
+		for i := range connectionRequests {
+			connectionRequests[i].Online = online[connectionRequests[i].UserID]
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": connectionRequests})
 }
 
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"unifriend-api/models"
+	"unifriend-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const dataExportFileName = "unifriend-data.json"
+
+func RequestDataExport(c *gin.Context, queue services.DataExportQueue) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	if models.HasDataExportInProgress(userIDUint) {
+		c.JSON(http.StatusConflict, gin.H{"error": "an export is already being generated"})
+		return
+	}
+
+	dataExport, err := models.CreateDataExport(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	if err := queue.Enqueue(dataExport); err != nil {
+		dataExport.MarkFailed()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "could not schedule the export, try again later"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"data": dataExport})
+}
+
+func GetDataExport(c *gin.Context) {
+	dataExport, ok := findUserDataExport(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dataExport})
+}
+
+func DownloadDataExport(c *gin.Context) {
+	dataExport, ok := findUserDataExport(c)
+	if !ok {
+		return
+	}
+
+	if !dataExport.IsDownloadable() {
+		c.JSON(http.StatusConflict, gin.H{"error": "export is not ready or has expired"})
+		return
+	}
+
+	file, err := os.Open(dataExport.FilePath)
+	if err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "export file is no longer available"})
+		return
+	}
+	defer file.Close()
+
+	if c.Query("format") != "zip" {
+		c.FileAttachment(dataExport.FilePath, dataExportFileName)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="unifriend-data-%d.zip"`, dataExport.ID))
+	c.Status(http.StatusOK)
+
+	// The zip is only finished when the whole archive was copied, so a
+	// failed copy leaves the client with a broken file instead of a valid
+	// but truncated one.
+	zipWriter := zip.NewWriter(c.Writer)
+
+	entry, err := zipWriter.Create(dataExportFileName)
+	if err == nil {
+		_, err = io.Copy(entry, file)
+	}
+	if err == nil {
+		err = zipWriter.Close()
+	}
+
+	if err != nil {
+		log.Printf("error streaming data export %d: %v", dataExport.ID, err)
+		c.Abort()
+	}
+}
+
+func findUserDataExport(c *gin.Context) (models.DataExport, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return models.DataExport{}, false
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return models.DataExport{}, false
+	}
+
+	exportID, err := strconv.ParseUint(c.Param("export_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID format"})
+		return models.DataExport{}, false
+	}
+
+	dataExport, err := models.GetUserDataExport(uint(exportID), userIDUint)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return models.DataExport{}, false
+	}
+
+	return dataExport, true
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"unifriend-api/models"
+	"unifriend-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceTokenKeysInput struct {
+	P256dh string `json:"p256dh" binding:"required,max=255"`
+	Auth   string `json:"auth" binding:"required,max=255"`
+}
+
+// RegisterDeviceTokenInput mirrors the PushSubscription the browser returns
+// from pushManager.subscribe.
+type RegisterDeviceTokenInput struct {
+	Endpoint string               `json:"endpoint" binding:"required,url,max=500"`
+	Keys     DeviceTokenKeysInput `json:"keys" binding:"required"`
+}
+
+func GetPushPublicKey(c *gin.Context) {
+	publicKey := services.VAPIDPublicKey()
+	if publicKey == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "push notifications are not configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"public_key": publicKey}})
+}
+
+func RegisterDeviceToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var input RegisterDeviceTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ValidatePushEndpoint(input.Endpoint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	sessionID, _ := c.Get("session_id")
+	sessionIDUint, _ := sessionID.(uint)
+
+	deviceToken, err := models.SaveDeviceToken(userIDUint, sessionIDUint, input.Endpoint, input.Keys.P256dh, input.Keys.Auth, userAgent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": deviceToken})
+}
+
+func GetDeviceTokens(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	deviceTokens, err := models.GetUserDeviceTokens(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve devices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deviceTokens})
+}
+
+func DeleteDeviceToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	deviceID, err := strconv.ParseUint(c.Param("device_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID format"})
+		return
+	}
+
+	deleted, err := models.DeleteUserDeviceToken(uint(deviceID), userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove device"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
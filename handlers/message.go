@@ -13,6 +13,10 @@ type SendMessageInput struct {
     Content string `json:"content" binding:"required"`
 }
 
+type MarkMessagesReadInput struct {
+    MessageID uint `json:"message_id" binding:"required"`
+}
+
 type UserDTO struct {
     UserID            uint   `json:"user_id"`
     Name              string `json:"name"`
@@ -22,6 +26,13 @@ type UserDTO struct {
 type MessageWithUser struct {
     Messages []models.Message `json:"messages"`
     User UserDTO `json:"user"`
+    HasMore bool `json:"has_more"`
+}
+
+type GetMessagesQuery struct {
+    Before uint `form:"before"`
+    After  uint `form:"after"`
+    Limit  int  `form:"limit" binding:"omitempty,min=1,max=100"`
 }
 
 var hub *services.Hub
@@ -41,6 +52,12 @@ func SendMessage(c *gin.Context) {
         return
     }
 
+    userIDUint, ok := userID.(uint)
+    if !ok {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+        return
+    }
+
     connectionID, err := strconv.ParseUint(c.Param("connection_id"), 10, 32)
     if err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid connection ID"})
@@ -53,24 +70,31 @@ func SendMessage(c *gin.Context) {
         return
     }
 
+    content, err := models.ValidateMessageContent(input.Content)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
     var connection models.Connection
     if err := models.DB.First(&connection, uint(connectionID)).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
         return
     }
 
-    if connection.UserAID != userID.(uint) && connection.UserBID != userID.(uint) {
+    if !connection.HasUser(userIDUint) {
         c.JSON(http.StatusForbidden, gin.H{"error": "You are not part of this connection"})
         return
     }
 
-    message := models.Message{
-        ConnectionID: uint(connectionID),
-        SenderID:     userID.(uint),
-        Content:      input.Content,
+    connection, err = models.GetActiveUserConnection(connection.ID, userIDUint)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+        return
     }
 
-    if err := models.DB.Create(&message).Error; err != nil {
+    message, err := models.CreateMessage(connection, userIDUint, content)
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
         return
     }
@@ -79,7 +103,89 @@ func SendMessage(c *gin.Context) {
         hub.Broadcast(&message)
     }
 
-    c.JSON(http.StatusCreated, message)
+    c.JSON(http.StatusCreated, gin.H{"data": message})
+}
+
+func MarkMessagesRead(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+        return
+    }
+
+    userIDUint, ok := userID.(uint)
+    if !ok {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+        return
+    }
+
+    connectionID, err := strconv.ParseUint(c.Param("connection_id"), 10, 32)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid connection ID"})
+        return
+    }
+
+    var input MarkMessagesReadInput
+    if err := c.ShouldBindJSON(&input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    var connection models.Connection
+    if err := models.DB.First(&connection, uint(connectionID)).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+        return
+    }
+
+    if !connection.HasUser(userIDUint) {
+        c.JSON(http.StatusForbidden, gin.H{"error": "You are not part of this connection"})
+        return
+    }
+
+    connection, err = models.GetActiveUserConnection(connection.ID, userIDUint)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Connection not found"})
+        return
+    }
+
+    if !models.MessageInConnection(input.MessageID, connection.ID) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+        return
+    }
+
+    updated, readAt, err := models.MarkMessagesRead(connection.ID, userIDUint, input.MessageID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark messages as read"})
+        return
+    }
+
+    if hub != nil && updated > 0 {
+        hub.NotifyRead(connection, userIDUint, input.MessageID, readAt)
+    }
+
+    c.JSON(http.StatusOK, gin.H{"data": gin.H{"updated": updated, "read_at": readAt}})
+}
+
+func GetUnreadCount(c *gin.Context) {
+    userID, exists := c.Get("user_id")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+        return
+    }
+
+    userIDUint, ok := userID.(uint)
+    if !ok {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+        return
+    }
+
+    count, err := models.CountUnreadMessages(userIDUint)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"data": gin.H{"unread_count": count}})
 }
 
 func GetMessages(c *gin.Context) {
@@ -106,8 +212,23 @@ func GetMessages(c *gin.Context) {
         return
     }
 
-    var messages []models.Message
-    if messages, err = models.GetMessages(uint(connectionID)); err != nil {
+    var query GetMessagesQuery
+    if err := c.ShouldBindQuery(&query); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    if query.Before > 0 && query.After > 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "before and after can not be used together"})
+        return
+    }
+
+    messages, hasMore, err := models.GetMessages(uint(connectionID), models.MessageCursor{
+        Before: query.Before,
+        After:  query.After,
+        Limit:  query.Limit,
+    })
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages"})
         return
     }
@@ -132,6 +253,7 @@ func GetMessages(c *gin.Context) {
             Name: otherUser.Name,
             ProfilePictureURL: otherUser.ProfilePictureURL,
         },
+        HasMore: hasMore,
     }
 
     c.JSON(http.StatusOK, gin.H{"data": response})
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"unifriend-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GetNotificationsQuery struct {
+	Before uint `form:"before"`
+	Limit  int  `form:"limit" binding:"omitempty,min=1,max=50"`
+	Unread bool `form:"unread"`
+}
+
+func GetNotifications(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var query GetNotificationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notifications, hasMore, err := models.GetNotifications(userIDUint, models.NotificationCursor{
+		Before:     query.Before,
+		Limit:      query.Limit,
+		UnreadOnly: query.Unread,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	unreadCount, err := models.CountUnreadNotifications(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         notifications,
+		"has_more":     hasMore,
+		"unread_count": unreadCount,
+	})
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	notificationID, err := strconv.ParseUint(c.Param("notification_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID format"})
+		return
+	}
+
+	found, err := models.MarkNotificationRead(uint(notificationID), userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
+		return
+	}
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	updated, err := models.MarkAllNotificationsRead(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"updated": updated}})
+}
+
+// notifyUser stores a notification and sends it to the sockets the user has
+// open. The action it reports already happened, so a failure is only logged.
+func notifyUser(userId uint, notificationType string, actorId uint, targetType string, targetId uint) {
+	notification, err := models.CreateNotification(userId, notificationType, actorId, targetType, targetId)
+	if err != nil {
+		log.Printf("error creating %s notification for user %d: %v", notificationType, userId, err)
+		return
+	}
+
+	if hub != nil {
+		hub.SendNotification(&notification)
+	}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 	"unifriend-api/models"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +15,10 @@ type SaveAnswersInput struct {
 	Answers []models.OptionTable `json:"answers" binding:"required"`
 }
 
+type UpdateAnswersInput struct {
+	Answers []models.OptionTable `json:"answers" binding:"required"`
+}
+
 type Answer struct {
 	QuestionID       uint `json:"question_id" binding:"required"`
 	SelectedOptionID uint `json:"option_id" binding:"required"`
@@ -32,16 +39,62 @@ type QuestionResponseFormat struct {
 	Options []OptionsformatForResponse `json:"options"`
 }
 
-// @Description	Get Quiz questions
+// AnsweredQuestionResponseFormat has no selected option for questions added
+// to the quiz after the user answered it.
+type AnsweredQuestionResponseFormat struct {
+	QuestionResponseFormat
+	SelectedOptionId *uint `json:"selected_option_id" example:"2"`
+}
+
+type QuizAnswersResponseFormat struct {
+	QuizId       uint                             `json:"quiz_id" example:"1"`
+	CompletedAt  *time.Time                       `json:"completed_at"`
+	NextRetakeAt *time.Time                       `json:"next_retake_at"`
+	Questions    []AnsweredQuestionResponseFormat `json:"questions"`
+}
+
+// @Description	List the published quizzes and whether the user completed them
 // @Accept			json
 // @Tags			quiz
 // @Produce		json
 // @Security		Bearer
-// @Success		200	{object}	controllers.QuestionResponseFormat
+// @Success		200	{array}	models.QuizWithProgress
 // @Failure		500	"Something went wrong"
-// @Router			/questions [get]
-func GetQuestions(c *gin.Context) {
+// @Router			/quizzes [get]
+func GetQuizzes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	quizzes, err := models.GetQuizzesWithProgress(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve quizzes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quizzes})
+}
 
+// @Description	Get the questions of one quiz
+// @Accept			json
+// @Tags			quiz
+// @Produce		json
+// @Param			quiz_id	path	int	true	"Quiz ID"
+// @Security		Bearer
+// @Success		200	{object}	controllers.QuestionResponseFormat
+// @Failure		403	"Quiz already taken"
+// @Failure		404	"Quiz not found"
+// @Failure		500	"Something went wrong"
+// @Router			/quizzes/{quiz_id}/questions [get]
+func GetQuizQuestions(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
@@ -54,56 +107,90 @@ func GetQuestions(c *gin.Context) {
 		return
 	}
 
-	hasTaken, err := models.HasUserAlreadyTakenQuiz(userIDUint)
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	quiz, err := models.GetPublishedQuiz(uint(quizID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+
+	hasTaken, err := models.HasUserAlreadyTakenQuiz(userIDUint, quiz.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	if hasTaken {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You have already taken the quiz"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "You have already taken this quiz"})
 		return
 	}
 
-	questions, err := models.GetQuestions()
-
+	questions, err := models.GetQuizQuestions(quiz.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	options, err := models.GetOptions()
+	c.JSON(http.StatusOK, gin.H{"error": false, "data": formatQuestions(questions)})
+}
+
+// @Description	Get the questions of every published quiz the user has not taken yet
+// @Accept			json
+// @Tags			quiz
+// @Produce		json
+// @Security		Bearer
+// @Success		200	{object}	controllers.QuestionResponseFormat
+// @Failure		500	"Something went wrong"
+// @Router			/questions [get]
+func GetQuestions(c *gin.Context) {
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	takenQuizIDs, err := models.GetTakenQuizIDs(userIDUint)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	optionsByQuestionId := make(map[uint][]OptionsformatForResponse)
-	for _, option := range options {
-		optionFormat := OptionsformatForResponse{
-			Id:   option.ID,
-			Text: option.Text,
-		}
+	questions, err := models.GetQuestions()
 
-		optionsByQuestionId[option.QuestionID] = append(optionsByQuestionId[option.QuestionID], optionFormat)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	var questionsResponse []QuestionResponseFormat
+	var remainingQuestions []models.QuestionTable
 	for _, question := range questions {
-		response := QuestionResponseFormat{
-			Id:      question.ID,
-			Text:    question.Text,
-			QuizId:  question.Quiz_id,
-			Options: optionsByQuestionId[question.ID],
+		if !takenQuizIDs[question.Quiz_id] {
+			remainingQuestions = append(remainingQuestions, question)
 		}
-		questionsResponse = append(questionsResponse, response)
 	}
 
-	if questionsResponse == nil {
+	if len(questions) > 0 && len(remainingQuestions) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You have already taken the quiz"})
+		return
+	}
+
+	if len(remainingQuestions) == 0 {
 		c.JSON(http.StatusOK, gin.H{})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{ "error" : false, "data" : questionsResponse})
+	c.JSON(http.StatusOK, gin.H{ "error" : false, "data" : formatQuestions(remainingQuestions)})
 }
 
 // @Description	SaveAnswers
@@ -113,6 +200,8 @@ func GetQuestions(c *gin.Context) {
 // @Param			input	body		SaveAnswersInput	true	"Save answers input"
 // @Success		201		{object}	controllers.SaveAnswerResponse
 // @Failure		400		"Invalid Data"
+// @Failure		403		"Quiz already taken"
+// @Failure		404		"Quiz not found"
 // @Failure		500	"Something went wrong"
 // @Security		Bearer
 // @Router			/answer/save [post]
@@ -130,38 +219,235 @@ func SaveAnswers(c *gin.Context) {
 		return
 	}
 
-	questionsWithOptions, err := models.GetQuestionsAndOptions(input.Answers)
+	quiz, err := models.GetPublishedQuiz(input.QuizID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
 
+	hasTaken, err := models.HasUserAlreadyTakenQuiz(userID.(uint), quiz.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if hasTaken {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You have already taken this quiz"})
+		return
+	}
 
-	if len(input.Answers) != len(questionsWithOptions) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "number of answers does not match number of questions"})
+	err = models.SaveQuizAnswers(userID.(uint), quiz, userResponsesFromAnswers(userID.(uint), input.Answers))
+	if err == models.ErrIncompleteAnswers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userResponses := []models.UserResponse{}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save user responses"})
+		return
+	}
 
-	for _, answer := range input.Answers {
+	c.JSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "answers saved successfully",
+	})
+}
 
-		userResponse := models.UserResponse{
-			UserID:     userID.(uint),
-			QuestionID: answer.QuestionID,
-			OptionID:   answer.ID,
+// @Description	Get my current answers to a quiz
+// @Accept			json
+// @Tags			quiz
+// @Produce		json
+// @Param			quiz_id	path	int	true	"Quiz ID"
+// @Security		Bearer
+// @Success		200	{object}	controllers.QuizAnswersResponseFormat
+// @Failure		404	"Quiz not found or not taken yet"
+// @Failure		500	"Something went wrong"
+// @Router			/quizzes/{quiz_id}/answers [get]
+func GetMyQuizAnswers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	quiz, err := models.GetPublishedQuiz(uint(quizID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+
+	userResponses, err := models.GetUserResponsesByUserID(userIDUint, quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(userResponses) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not taken this quiz yet"})
+		return
+	}
+
+	questions, err := models.GetQuizQuestions(quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	completion, err := models.GetQuizCompletion(userIDUint, quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	selectedOptions := make(map[uint]uint, len(userResponses))
+	for _, userResponse := range userResponses {
+		selectedOptions[userResponse.QuestionID] = userResponse.OptionID
+	}
+
+	response := QuizAnswersResponseFormat{
+		QuizId:    quiz.ID,
+		Questions: make([]AnsweredQuestionResponseFormat, 0, len(questions)),
+	}
+
+	if completion != nil {
+		nextRetakeAt := completion.NextRetakeAt()
+		response.CompletedAt = &completion.CompletedAt
+		response.NextRetakeAt = &nextRetakeAt
+	}
+
+	for _, question := range formatQuestions(questions) {
+		answered := AnsweredQuestionResponseFormat{QuestionResponseFormat: question}
+		if optionID, ok := selectedOptions[question.Id]; ok {
+			answered.SelectedOptionId = &optionID
 		}
 
-		userResponses = append(userResponses, userResponse)
+		response.Questions = append(response.Questions, answered)
 	}
 
-	if err := models.SaveUserResponses(userResponses); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save user responses"})
+	c.JSON(http.StatusOK, gin.H{"data": response})
+}
+
+// @Description	Replace my answers to a quiz I already took
+// @Accept			json
+// @Tags			quiz
+// @Produce		json
+// @Param			quiz_id	path	int	true	"Quiz ID"
+// @Param			input	body		UpdateAnswersInput	true	"Update answers input"
+// @Success		200		{object}	controllers.SaveAnswerResponse
+// @Failure		400		"Invalid Data"
+// @Failure		404		"Quiz not found or not taken yet"
+// @Failure		429		"Answers changed too recently"
+// @Failure		500	"Something went wrong"
+// @Security		Bearer
+// @Router			/quizzes/{quiz_id}/answers [put]
+func UpdateQuizAnswers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("quiz_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID format"})
+		return
+	}
+
+	var input UpdateAnswersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quiz, err := models.GetPublishedQuiz(uint(quizID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		return
+	}
+
+	userResponses, err := models.GetUserResponsesByUserID(userIDUint, quiz.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(userResponses) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You have not taken this quiz yet"})
+		return
+	}
+
+	err = models.UpdateQuizAnswers(userIDUint, quiz, userResponsesFromAnswers(userIDUint, input.Answers))
+
+	var cooldownErr *models.RetakeCooldownError
+	if errors.As(err, &cooldownErr) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "You changed your answers too recently", "next_retake_at": cooldownErr.NextRetakeAt})
+		return
+	}
+
+	if err == models.ErrIncompleteAnswers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user responses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
 		"error":   false,
-		"message": "answers saved successfully",
+		"message": "answers updated successfully",
 	})
 }
+
+func userResponsesFromAnswers(userID uint, answers []models.OptionTable) []models.UserResponse {
+	userResponses := make([]models.UserResponse, 0, len(answers))
+	for _, answer := range answers {
+		userResponses = append(userResponses, models.UserResponse{
+			UserID:     userID,
+			QuestionID: answer.QuestionID,
+			OptionID:   answer.ID,
+		})
+	}
+
+	return userResponses
+}
+
+func formatQuestions(questions []models.QuestionTable) []QuestionResponseFormat {
+	questionsResponse := make([]QuestionResponseFormat, 0, len(questions))
+	for _, question := range questions {
+		options := make([]OptionsformatForResponse, 0, len(question.Options))
+		for _, option := range question.Options {
+			options = append(options, OptionsformatForResponse{
+				Id:   option.ID,
+				Text: option.Text,
+			})
+		}
+
+		questionsResponse = append(questionsResponse, QuestionResponseFormat{
+			Id:      question.ID,
+			Text:    question.Text,
+			QuizId:  question.Quiz_id,
+			Options: options,
+		})
+	}
+
+	return questionsResponse
+}
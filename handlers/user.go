@@ -3,6 +3,7 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -48,10 +49,13 @@ type UserResponse struct {
 	Data []User `json:"data"`
 }
 
+// GetResultsInput matches on the answers to every quiz unless quiz_id is
+// given, once for a single quiz or repeated to combine several.
 type GetResultsInput struct {
 	UserId uint `uri:"user_id" binding:"required"`
 	Page int `form:"page"`
 	Limit int `form:"limit"`
+	QuizIDs []uint `form:"quiz_id"`
 }
 
 type PaginatedUserResponse struct {
@@ -73,6 +77,56 @@ type UserLoginRegisterResponse struct {
 
 }
 
+type UserProfileResponse struct {
+	UserID         uint                 `json:"id"`
+	Name           string               `json:"name"`
+	Email          string               `json:"email"`
+	PhoneNumber    string               `json:"phone_number"`
+	ProfilePicture string               `json:"profile_picture_url"`
+	Bio            string               `json:"bio"`
+	Major          models.Major         `json:"major"`
+	Images         []models.UsersImages `json:"images"`
+}
+
+type PublicUserProfileResponse struct {
+	UserID                      uint                 `json:"id"`
+	Name                        string               `json:"name"`
+	Email                       string               `json:"email,omitempty"`
+	PhoneNumber                 string               `json:"phone_number,omitempty"`
+	ProfilePicture              string               `json:"profile_picture_url"`
+	Bio                         string               `json:"bio"`
+	Major                       models.Major         `json:"major"`
+	Images                      []models.UsersImages `json:"images"`
+	HasConnection               bool                 `json:"has_connection"`
+	HasPendingConnectionRequest bool                 `json:"has_pending_connection_request"`
+	ConnectionID                uint                 `json:"connection_id,omitempty"`
+}
+
+type UpdateProfileInput struct {
+	Name        *string `json:"name"`
+	PhoneNumber *string `json:"phone_number"`
+	MajorID     *uint   `json:"major_id"`
+	Bio         *string `json:"bio"`
+}
+
+const maxBioLength = 500
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Password        string `json:"password" binding:"required"`
+	RePassword      string `json:"re_password" binding:"required"`
+}
+
+type ChangeEmailInput struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeInput struct {
+	Email            string `json:"email" binding:"required"`
+	VerificationCode int    `json:"verification_code" binding:"required"`
+}
+
 type VerifyEmailInput struct {
 	Email string `uri:"email" binding:"required"`
 }
@@ -371,6 +425,353 @@ func GetVerificationCodeExpiration(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"expiration_time": 0})
 }
 
+func GetMyProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	user, err := models.GetActiveUserProfile(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"error": false, "data": buildUserProfileResponse(user)})
+}
+
+func UpdateMyProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var input UpdateProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" || len(name) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must have between 1 and 100 characters"})
+			return
+		}
+		updates["Name"] = name
+	}
+
+	if input.PhoneNumber != nil {
+		phoneNumber := strings.TrimSpace(*input.PhoneNumber)
+		if phoneNumber == "" || len(phoneNumber) > 20 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid phone number"})
+			return
+		}
+		if models.PhoneNumberUsedByOtherUser(phoneNumber, userIDUint) {
+			c.JSON(http.StatusConflict, gin.H{"error": "phone number is already in use"})
+			return
+		}
+		updates["PhoneNumber"] = phoneNumber
+	}
+
+	if input.MajorID != nil {
+		if !models.MajorExists(*input.MajorID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "major not found"})
+			return
+		}
+		updates["MajorID"] = *input.MajorID
+	}
+
+	if input.Bio != nil {
+		bio := strings.TrimSpace(*input.Bio)
+		if len([]rune(bio)) > maxBioLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bio must have at most %d characters", maxBioLength)})
+			return
+		}
+		updates["Bio"] = bio
+	}
+
+	user, err := models.GetActiveUserProfile(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := user.UpdateProfile(updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	user, err = models.GetActiveUserProfile(userIDUint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"error": false, "data": buildUserProfileResponse(user)})
+}
+
+func GetUserProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	profileUserID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	user, err := models.GetVisibleUserProfile(userIDUint, uint(profileUserID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	response := PublicUserProfileResponse{
+		UserID:         user.ID,
+		Name:           user.Name,
+		ProfilePicture: user.ProfilePictureURL,
+		Bio:            user.Bio,
+		Major:          user.Major,
+		Images:         user.Images,
+	}
+
+	if response.Images == nil {
+		response.Images = make([]models.UsersImages, 0)
+	}
+
+	if user.ID == userIDUint {
+		response.Email = user.Email
+		response.PhoneNumber = user.PhoneNumber
+	} else if connection, err := models.GetConnectionBetweenUsers(userIDUint, user.ID); err == nil {
+		response.HasConnection = true
+		response.ConnectionID = connection.ID
+		response.Email = user.Email
+		response.PhoneNumber = user.PhoneNumber
+	} else {
+		response.HasPendingConnectionRequest = models.HasPendingConnectionRequestBetweenUsers(userIDUint, user.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"error": false, "data": response})
+}
+
+func buildUserProfileResponse(user models.User) UserProfileResponse {
+	images := user.Images
+	if images == nil {
+		images = make([]models.UsersImages, 0)
+	}
+
+	return UserProfileResponse{
+		UserID:         user.ID,
+		Name:           user.Name,
+		Email:          user.Email,
+		PhoneNumber:    user.PhoneNumber,
+		ProfilePicture: user.ProfilePictureURL,
+		Bio:            user.Bio,
+		Major:          user.Major,
+		Images:         images,
+	}
+}
+
+func ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Password != input.RePassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password and re_password are not the same"})
+		return
+	}
+
+	if !isValidPassword(input.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters long, contain at least one uppercase letter, and one special symbol"})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userIDUint).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := models.VerifyPassword(input.CurrentPassword, user.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+
+	if err := user.UpdatePassword(input.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	sessionID, _ := c.Get("session_id")
+	sessionIDUint, _ := sessionID.(uint)
+
+	if err := models.RevokeOtherUserSessions(userIDUint, sessionIDUint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password updated successfully"})
+}
+
+func ChangeEmail(c *gin.Context, emailSender services.SesSender) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var input ChangeEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userIDUint).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := models.VerifyPassword(input.Password, user.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+
+	if !isValidEmail(input.Email) || models.UsernameAlreadyUsed(input.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email inválido"})
+		return
+	}
+
+	if pending, err := models.GetValidEmailChange(userIDUint, input.Email); err == nil {
+		expirationTime := pending.Expiration.Sub(time.Now().UTC()).Truncate(time.Second).Seconds()
+		c.JSON(http.StatusOK, gin.H{"error": "There is already a valid code for this email", "expiration_time": expirationTime})
+		return
+	}
+
+	verificationCode, err := newSecurityCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	emailChange, err := models.SaveEmailChangeCode(userIDUint, input.Email, verificationCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	if err := emailSender.SendVerificationEmail(input.Email, Subject, Message+strconv.Itoa(verificationCode)); err != nil {
+		if err := emailChange.Discard(); err != nil {
+			log.Printf("error discarding undelivered email change code: %v", err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+		return
+	}
+
+	expirationTime := emailChange.Expiration.Sub(time.Now().UTC()).Truncate(time.Second).Seconds()
+	c.JSON(http.StatusCreated, gin.H{"message": "email was sent", "expiration_time": expirationTime})
+}
+
+func ConfirmEmailChange(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+		return
+	}
+
+	userIDUint, ok := userID.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid User ID format"})
+		return
+	}
+
+	var input ConfirmEmailChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email or code is necessary"})
+		return
+	}
+
+	emailChange, err := models.GetLatestEmailChange(userIDUint, input.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "verification code not found"})
+		return
+	}
+
+	if !emailChange.IsValid(input.VerificationCode) {
+		if err := emailChange.RegisterFailedAttempt(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "something went wrong"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "code expired or is incorrect"})
+		return
+	}
+
+	if !isValidEmail(emailChange.Email) || models.UsernameAlreadyUsed(emailChange.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email inválido"})
+		return
+	}
+
+	var user models.User
+	if err := models.DB.First(&user, userIDUint).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := models.ConfirmUserEmailChange(&user, emailChange); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email updated successfully", "email": emailChange.Email})
+}
+
 func GetResults(c *gin.Context) {
 	var userGetResultsInput GetResultsInput
 	if err := c.BindUri(&userGetResultsInput); err != nil {
@@ -398,7 +799,7 @@ func GetResults(c *gin.Context) {
 		return
 	}
 
-	currentUserResponses, err := models.GetUserResponsesByUserID(userGetResultsInput.UserId)
+	currentUserResponses, err := models.GetUserResponsesByUserID(userGetResultsInput.UserId, userGetResultsInput.QuizIDs...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve your responses. Please try again."})
 		return
@@ -546,7 +947,8 @@ func isValidEmail(email string) bool {
 		return false
 	}
 
-	return models.EmailDomainExists(emailParts[1])
+	// Domains are stored lowercased, but users type them however they like.
+	return models.EmailDomainExists(strings.ToLower(emailParts[1]))
 }
 
 func DeleteUserAccount(c *gin.Context, uploader services.S3Uploader) {
@@ -563,14 +965,20 @@ func DeleteUserAccount(c *gin.Context, uploader services.S3Uploader) {
 	}
 
 	var user models.User
-	if err := models.DB.First(&user, userIDUint).Error; err != nil {
+	if err := models.DB.Preload("Images").First(&user, userIDUint).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
 	if user.ProfilePictureURL != "" {
 		if err := deleteImage(user.ProfilePictureURL, uploader); err != nil {
-			fmt.Printf("Warning: Failed to delete profile picture: %v\n", err)
+			log.Printf("failed to delete profile picture of user %d: %v", user.ID, err)
+		}
+	}
+
+	for _, image := range user.Images {
+		if err := deleteImage(image.ImageUrl, uploader); err != nil {
+			log.Printf("failed to delete image %d of user %d: %v", image.ID, user.ID, err)
 		}
 	}
 
@@ -579,5 +987,6 @@ func DeleteUserAccount(c *gin.Context, uploader services.S3Uploader) {
 		return
 	}
 
+	clearAuthCookies(c)
 	c.Status(http.StatusNoContent)
 }
\ No newline at end of file
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 	"unifriend-api/models"
 	"unifriend-api/routes"
 	"unifriend-api/services"
@@ -31,6 +38,8 @@ import (
 //	@in							header
 //	@name						Authorization
 
+const defaultShutdownTimeoutSeconds = 15
+
 func main() {
 	r := gin.Default()
 
@@ -42,18 +51,120 @@ func main() {
 
 	models.ConnectDataBase()
 
-	hub := services.NewHub()
-    go hub.Run()
+	backplane, err := services.NewBackplane()
+	if err != nil {
+		log.Fatalf("Error configuring chat backplane: %v", err)
+	}
+
+	presence, err := services.NewPresenceStore()
+	if err != nil {
+		log.Fatalf("Error configuring chat presence: %v", err)
+	}
+
+	hub, err := services.NewHubWithBackplane(backplane, presence)
+	if err != nil {
+		log.Fatalf("Error subscribing to chat backplane: %v", err)
+	}
+
+	notifier, err := services.NewWebPushNotifier()
+	if err != nil {
+		log.Printf("Push notifications disabled: %v", err)
+	} else {
+		hub.SetNotifier(notifier)
+	}
+
+	go hub.Run()
+
+	accountPurger := services.NewAccountPurger()
+	go accountPurger.Run()
+
+	dataExporter := services.NewDataExporter()
+	go dataExporter.Run()
 
 	corsConfig := cors.Config{
 		AllowOrigins:     []string{os.Getenv("CLIENT_DOMAIN")},
-		AllowMethods:     []string{"POST", "GET", "OPTIONS", "PUT", "DELETE"},
+		AllowMethods:     []string{"POST", "GET", "OPTIONS", "PUT", "PATCH", "DELETE"},
 		AllowHeaders:     []string{"Content-Type"},
 		AllowCredentials: true,
 	}
 
 	r.Use(cors.New(corsConfig))
-	routes.SetupRoutes(r, hub)
+	routes.SetupRoutes(r, hub, dataExporter)
+
+	srv := &http.Server{
+		Addr:    ":8090",
+		Handler: r,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error starting server: %v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+
+	// Stop taking requests first so no socket or export is started while the
+	// rest is being drained.
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+
+	// Anything that did not finish in time may still be writing to the
+	// database, so it is only closed once everything drained.
+	drained := true
+
+	if err := hub.Shutdown(ctx); err != nil {
+		log.Printf("Error closing chat connections: %v", err)
+		drained = false
+	}
+
+	if err := backplane.Close(); err != nil {
+		log.Printf("Error closing chat backplane: %v", err)
+	}
+
+	if err := presence.Close(); err != nil {
+		log.Printf("Error closing chat presence: %v", err)
+	}
+
+	if notifier != nil {
+		if err := notifier.Shutdown(ctx); err != nil {
+			log.Printf("Error finishing push notifications: %v", err)
+			drained = false
+		}
+	}
+
+	if err := accountPurger.Shutdown(ctx); err != nil {
+		log.Printf("Error finishing account purge: %v", err)
+		drained = false
+	}
+
+	if err := dataExporter.Shutdown(ctx); err != nil {
+		log.Printf("Error finishing data exports: %v", err)
+		drained = false
+	}
+
+	if !drained {
+		log.Println("Leaving the database open for the work still running")
+	} else if err := models.CloseDataBase(); err != nil {
+		log.Printf("Error closing database: %v", err)
+	}
+
+	log.Println("Server stopped")
+}
+
+func shutdownTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		seconds = defaultShutdownTimeoutSeconds
+	}
 
-	r.Run(":8090")
+	return time.Duration(seconds) * time.Second
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"unifriend-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware must run after AuthMiddleware. It reloads the user on every
+// request, so revoking IsAdmin takes effect right away.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
+			c.Abort()
+			return
+		}
+
+		user, err := models.GetUserByID(userID.(uint))
+		if err != nil || !user.IsAdmin || user.Status != models.UserStatusActive || user.DeletedAt != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"os"
+	"unifriend-api/models"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
@@ -27,13 +28,22 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			if userIDFloat, ok := claims["user_id"].(float64); ok {
-				c.Set("user_id", uint(userIDFloat))
-			} else {
+			userIDFloat, userOk := claims["user_id"].(float64)
+			sessionIDFloat, sessionOk := claims["session_id"].(float64)
+			if !userOk || !sessionOk {
 				c.JSON(http.StatusUnauthorized, gin.H{"error" : "invalid token"})
 				c.Abort()
                 return
 			}
+
+			if !models.IsSessionActive(uint(sessionIDFloat), uint(userIDFloat)) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", uint(userIDFloat))
+			c.Set("session_id", uint(sessionIDFloat))
 			
 			c.Next()
 		} else {
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditUsersSearched    = "user.searched"
+	AuditUserViewed       = "user.viewed"
+	AuditUserSuspended    = "user.suspended"
+	AuditUserReactivated  = "user.reactivated"
+	AuditUserImagesViewed = "user.images_viewed"
+
+	AuditReportsListed  = "report.listed"
+	AuditReportViewed   = "report.viewed"
+	AuditReportReviewed = "report.reviewed"
+
+	AuditMajorCreated = "major.created"
+	AuditMajorUpdated = "major.updated"
+	AuditMajorDeleted = "major.deleted"
+
+	AuditEmailDomainCreated = "email_domain.created"
+	AuditEmailDomainUpdated = "email_domain.updated"
+	AuditEmailDomainDeleted = "email_domain.deleted"
+
+	AuditQuizCreated        = "quiz.created"
+	AuditQuizUpdated        = "quiz.updated"
+	AuditQuizDeleted        = "quiz.deleted"
+	AuditQuizPublished      = "quiz.published"
+	AuditQuizUnpublished    = "quiz.unpublished"
+	AuditQuestionsReordered = "quiz.questions_reordered"
+	AuditQuestionCreated    = "question.created"
+	AuditQuestionUpdated    = "question.updated"
+	AuditQuestionDeleted    = "question.deleted"
+	AuditOptionCreated      = "option.created"
+	AuditOptionUpdated      = "option.updated"
+	AuditOptionDeleted      = "option.deleted"
+	AuditOptionsReordered   = "question.options_reordered"
+
+	AuditTargetUser        = "user"
+	AuditTargetReport      = "report"
+	AuditTargetMajor       = "major"
+	AuditTargetEmailDomain = "email_domain"
+	AuditTargetQuiz        = "quiz"
+	AuditTargetQuestion    = "question"
+	AuditTargetOption      = "option"
+
+	DefaultAuditLogPageSize = 50
+	MaxAuditLogPageSize     = 100
+)
+
+// AuditDetails is stored as a JSON document next to the audit entry, it holds
+// whatever the action needs to be understood later, e.g. the previous name of
+// a renamed major.
+type AuditDetails map[string]interface{}
+
+func (d AuditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(d)
+	return string(data), err
+}
+
+func (d *AuditDetails) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	}
+
+	return errors.New("unsupported audit details value")
+}
+
+// AuditLog records an action an admin took, it is written in the same
+// transaction as the change itself. Looking at users and reports is recorded
+// too, a search or a listing has no single target so its TargetID is zero. AdminID has no foreign key so the log
+// outlives purged accounts.
+type AuditLog struct {
+	ID         uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	AdminID    uint         `gorm:"not null;index" json:"admin_id"`
+	Action     string       `gorm:"size:50;not null;index" json:"action"`
+	TargetType string       `gorm:"size:50;not null;index:idx_audit_logs_target,priority:1" json:"target_type"`
+	TargetID   uint         `gorm:"not null;index:idx_audit_logs_target,priority:2" json:"target_id"`
+	Details    AuditDetails `gorm:"type:text" json:"details"`
+	CreatedAt  time.Time    `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type AuditLogWithAdmin struct {
+	AuditLog  `gorm:"embedded"`
+	AdminName string `json:"admin_name"`
+}
+
+// AuditLogFilter selects one page of the audit log, newest first. Zero
+// values match everything.
+type AuditLogFilter struct {
+	AdminID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	Before     uint
+	Limit      int
+}
+
+func RecordAudit(tx *gorm.DB, adminId uint, action string, targetType string, targetId uint, details AuditDetails) error {
+	return tx.Create(&AuditLog{
+		AdminID:    adminId,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetId,
+		Details:    details,
+	}).Error
+}
+
+func GetAuditLogs(filter AuditLogFilter) ([]AuditLogWithAdmin, bool, error) {
+	results := make([]AuditLogWithAdmin, 0)
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxAuditLogPageSize {
+		limit = DefaultAuditLogPageSize
+	}
+
+	query := DB.Table("audit_logs as a").
+		Select("a.*, u.name as admin_name").
+		Joins("LEFT JOIN users u ON u.id = a.admin_id")
+
+	if filter.AdminID > 0 {
+		query = query.Where("a.admin_id = ?", filter.AdminID)
+	}
+
+	if filter.Action != "" {
+		query = query.Where("a.action = ?", filter.Action)
+	}
+
+	if filter.TargetType != "" {
+		query = query.Where("a.target_type = ?", filter.TargetType)
+	}
+
+	if filter.TargetID > 0 {
+		query = query.Where("a.target_id = ?", filter.TargetID)
+	}
+
+	if filter.Before > 0 {
+		query = query.Where("a.id < ?", filter.Before)
+	}
+
+	if err := query.Order("a.id desc").Limit(limit + 1).Scan(&results).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	return results, hasMore, nil
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Block stops all contact between BlockerID and BlockedID, in both
+// directions. Only the blocker can lift it.
+type Block struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	BlockerID uint      `gorm:"not null;uniqueIndex:idx_blocks_blocker_blocked,priority:1" json:"blocker_id"`
+	Blocker   User      `gorm:"foreignKey:BlockerID;constraint:OnDelete:CASCADE" json:"-"`
+	BlockedID uint      `gorm:"not null;index;uniqueIndex:idx_blocks_blocker_blocked,priority:2" json:"blocked_id"`
+	Blocked   User      `gorm:"foreignKey:BlockedID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type BlockedUser struct {
+	UserID            uint      `json:"user_id"`
+	Name              string    `json:"name"`
+	ProfilePictureURL string    `json:"profile_picture_url"`
+	BlockedAt         time.Time `json:"blocked_at"`
+}
+
+// notBlocked is a condition on column that leaves out the users who blocked
+// the user passed twice as its arguments, or were blocked by them.
+func notBlocked(column string) string {
+	return column + ` NOT IN (
+            SELECT b.blocked_id FROM blocks b WHERE b.blocker_id = ?
+            UNION
+            SELECT b.blocker_id FROM blocks b WHERE b.blocked_id = ?
+        )`
+}
+
+// BlockUser records the block and tears down everything that lets the two
+// users reach each other: their connection with its messages and every
+// connection request between them. Blocking twice is not an error.
+func BlockUser(blockerId uint, blockedId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		block := Block{BlockerID: blockerId, BlockedID: blockedId}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&block).Error; err != nil {
+			return err
+		}
+
+		connectionIds := tx.Model(&Connection{}).Select("id").
+			Where("(user_a = ? AND user_b = ?) OR (user_a = ? AND user_b = ?)", blockerId, blockedId, blockedId, blockerId)
+
+		if err := tx.Where("connection_id IN (?)", connectionIds).Delete(&Message{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("(user_a = ? AND user_b = ?) OR (user_a = ? AND user_b = ?)", blockerId, blockedId, blockedId, blockerId).
+			Delete(&Connection{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("(requesting_user_id = ? AND requested_user_id = ?) OR (requesting_user_id = ? AND requested_user_id = ?)",
+			blockerId, blockedId, blockedId, blockerId).Delete(&ConnectionRequest{}).Error
+	})
+}
+
+// UnblockUser reports false when blockerId had not blocked blockedId.
+func UnblockUser(blockerId uint, blockedId uint) (bool, error) {
+	result := DB.Where("blocker_id = ? AND blocked_id = ?", blockerId, blockedId).Delete(&Block{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// IsBlocked reports whether either user blocked the other.
+func IsBlocked(userId uint, otherUserId uint) bool {
+	var count int64
+	err := DB.Model(&Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", userId, otherUserId, otherUserId, userId).
+		Count(&count).Error
+
+	return err == nil && count > 0
+}
+
+// GetBlockedUserIDs returns the users userId blocked and the ones who blocked
+// userId.
+func GetBlockedUserIDs(userId uint) ([]uint, error) {
+	var userIds []uint
+
+	err := DB.Raw(`
+            SELECT blocked_id FROM blocks WHERE blocker_id = ?
+            UNION
+            SELECT blocker_id FROM blocks WHERE blocked_id = ?
+        `, userId, userId).Scan(&userIds).Error
+
+	return userIds, err
+}
+
+func GetBlockedUsers(blockerId uint) ([]BlockedUser, error) {
+	results := make([]BlockedUser, 0)
+
+	err := DB.Table("blocks as b").
+		Select("u.id as user_id, u.name, u.profile_picture_url, b.created_at as blocked_at").
+		Joins("JOIN users u ON u.id = b.blocked_id").
+		Where("b.blocker_id = ?", blockerId).
+		Order("b.created_at desc").
+		Scan(&results).Error
+
+	return results, err
+}
@@ -27,6 +27,35 @@ type ConnectionWithUser struct {
     Content          string    `json:"content"`
     Created          time.Time `json:"created"`
     ReadAt          *time.Time `json:"read_at"`
+    UnreadCount      int64     `json:"unread_count"`
+    Online           bool      `gorm:"-" json:"online"`
+    LastSeenAt       *time.Time `json:"last_seen_at"`
+}
+
+func (c *Connection) HasUser(userId uint) bool {
+    return c.UserAID == userId || c.UserBID == userId
+}
+
+func (c *Connection) OtherUserID(userId uint) uint {
+    if c.UserAID == userId {
+        return c.UserBID
+    }
+    return c.UserAID
+}
+
+// GetActiveUserConnection returns the connection only when userId is part of
+// it and the other user has not been suspended or deleted.
+func GetActiveUserConnection(connectionId uint, userId uint) (Connection, error) {
+    var connection Connection
+
+    err := DB.Table("connections as c").
+        Select("c.*").
+        Joins("JOIN users u ON u.id = CASE WHEN c.user_a = ? THEN c.user_b ELSE c.user_a END", userId).
+        Where("c.id = ? AND (c.user_a = ? OR c.user_b = ?)", connectionId, userId, userId).
+        Where("u.status = 1 AND u.deleted_at IS NULL").
+        Take(&connection).Error
+
+    return connection, err
 }
 
 func DeleteConnection(connectionId uint, userId uint) error {
@@ -52,13 +81,37 @@ func DeleteConnection(connectionId uint, userId uint) error {
     })
 }
 
+func GetConnectionBetweenUsers(userId uint, otherUserId uint) (Connection, error) {
+    var connection Connection
+
+    err := DB.Where("(user_a = ? AND user_b = ?) OR (user_a = ? AND user_b = ?)", userId, otherUserId, otherUserId, userId).
+        First(&connection).Error
+
+    return connection, err
+}
+
+func GetConnectedUserIDs(userId uint) ([]uint, error) {
+    var userIds []uint
+
+    err := DB.Table("connections as c").
+        Select("u.id").
+        Joins("JOIN users u ON u.id = CASE WHEN c.user_a = ? THEN c.user_b ELSE c.user_a END", userId).
+        Where("c.user_a = ? OR c.user_b = ?", userId, userId).
+        Where("u.status = 1 AND u.deleted_at IS NULL").
+        Where(notBlocked("u.id"), userId, userId).
+        Pluck("u.id", &userIds).Error
+
+    return userIds, err
+}
+
 func GetConnections(userId uint) ([]ConnectionWithUser, error) {
     var results []ConnectionWithUser
 
     err := DB.Table("connections as c").
         Select(`c.id, c.user_a, c.user_b, c.created_at, c.connection_request_id, 
-                u.id as user_id, u.name, u.profile_picture_url, 
-                m.id as message_id, m.content, m.created_at as created, m.read_at`).
+                u.id as user_id, u.name, u.profile_picture_url, u.last_seen_at,
+                m.id as message_id, m.content, m.created_at as created, m.read_at,
+                COALESCE(unread.unread_count, 0) as unread_count`).
         Joins(`JOIN (
                 SELECT connection_id, MAX(created_at) AS latest_message_time 
                 FROM messages 
@@ -66,9 +119,16 @@ func GetConnections(userId uint) ([]ConnectionWithUser, error) {
               ) latest ON latest.connection_id = c.id`).
         Joins(`JOIN messages m ON m.connection_id = latest.connection_id 
                AND m.created_at = latest.latest_message_time`).
+        Joins(`LEFT JOIN (
+                SELECT connection_id, COUNT(*) AS unread_count
+                FROM messages
+                WHERE receiver_id = ? AND read_at IS NULL
+                GROUP BY connection_id
+              ) unread ON unread.connection_id = c.id`, userId).
         Joins(`JOIN users u ON u.id = CASE WHEN c.user_a = ? THEN c.user_b ELSE c.user_a END`, userId).
         Where("c.user_a = ? OR c.user_b = ?", userId, userId).
         Where("u.status = 1 AND u.deleted_at IS NULL").
+        Where(notBlocked("u.id"), userId, userId).
         Find(&results).Error
 
     return results, err
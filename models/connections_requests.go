@@ -47,6 +47,7 @@ func ValidConnectionRequest(requestingUserId uint, requestedUserId uint) (bool)
             SELECT cr.requested_user_id FROM connection_requests cr WHERE cr.requesting_user_id = ? AND cr.status = 2
         )
     `, requestingUserId, requestingUserId).
+    Where(notBlocked("u2.id"), requestingUserId, requestingUserId).
     Count(&count).Error
 
     if err != nil {
@@ -88,4 +89,19 @@ func GetConnectionRequestById(requestId uint, userId uint) (ConnectionRequest, e
         requestId, StatusPending, userId,
     ).First(&connectionRequest).Error
     return connectionRequest, err
-}
\ No newline at end of file
+}
+
+func HasPendingConnectionRequestBetweenUsers(userId uint, otherUserId uint) bool {
+    var count int64
+
+    err := DB.Model(&ConnectionRequest{}).
+        Where("status = ?", StatusPending).
+        Where("(requesting_user_id = ? AND requested_user_id = ?) OR (requesting_user_id = ? AND requested_user_id = ?)", userId, otherUserId, otherUserId, userId).
+        Count(&count).Error
+
+    if err != nil {
+        return false
+    }
+
+    return count > 0
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+type DataExport struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Status      int        `gorm:"type:int;default:0" json:"status"`
+	FilePath    string     `gorm:"size:255" json:"-"`
+	CreatedAt   time.Time  `gorm:"precision:3;autoCreateTime" json:"created_at"`
+	CompletedAt *time.Time `gorm:"precision:3;default:NULL" json:"completed_at"`
+	ExpiresAt   *time.Time `gorm:"precision:3;default:NULL" json:"expires_at"`
+}
+
+const (
+	ExportPending    int = 0
+	ExportProcessing int = 1
+	ExportReady      int = 2
+	ExportFailed     int = 3
+)
+
+const defaultDataExportStaleMinutes = 60
+
+type ExportedUser struct {
+	ID                uint   `json:"id"`
+	Email             string `json:"email"`
+	Name              string `json:"name"`
+	PhoneNumber       string `json:"phone_number"`
+	Bio               string `json:"bio"`
+	Major             string `json:"major"`
+	ProfilePictureURL string `json:"profile_picture_url"`
+}
+
+type ExportedQuizAnswer struct {
+	QuestionID uint   `json:"question_id"`
+	Question   string `json:"question"`
+	OptionID   uint   `json:"option_id"`
+	Option     string `json:"option"`
+}
+
+type UserDataArchive struct {
+	GeneratedAt        time.Time            `json:"generated_at"`
+	User               ExportedUser         `json:"user"`
+	ImageURLs          []string             `json:"image_urls"`
+	QuizAnswers        []ExportedQuizAnswer `json:"quiz_answers"`
+	ConnectionRequests []ConnectionRequest  `json:"connection_requests"`
+	Connections        []Connection         `json:"connections"`
+	Messages           []Message            `json:"messages"`
+}
+
+func CreateDataExport(userId uint) (*DataExport, error) {
+	dataExport := &DataExport{UserID: userId, Status: ExportPending}
+
+	if err := DB.Create(dataExport).Error; err != nil {
+		return nil, err
+	}
+
+	return dataExport, nil
+}
+
+func GetUserDataExport(exportId uint, userId uint) (DataExport, error) {
+	var dataExport DataExport
+	err := DB.Where("id = ? AND user_id = ?", exportId, userId).First(&dataExport).Error
+	return dataExport, err
+}
+
+func HasDataExportInProgress(userId uint) bool {
+	var count int64
+
+	err := DB.Model(&DataExport{}).
+		Where("user_id = ? AND status IN ?", userId, []int{ExportPending, ExportProcessing}).
+		Where("created_at >= ?", time.Now().Add(-dataExportStaleAfter())).
+		Count(&count).Error
+
+	if err != nil {
+		return false
+	}
+
+	return count > 0
+}
+
+// FailStaleDataExports marks as failed the exports that stayed pending or
+// processing for longer than they ever should.
+func FailStaleDataExports() (int64, error) {
+	return failDataExportsCreatedBefore(time.Now().Add(-dataExportStaleAfter()))
+}
+
+// FailInterruptedDataExports marks as failed the exports queued before the
+// exporter started. The queue lives in memory, so they were lost when the
+// previous process stopped and will never run.
+func FailInterruptedDataExports(startedAt time.Time) (int64, error) {
+	return failDataExportsCreatedBefore(startedAt)
+}
+
+func failDataExportsCreatedBefore(cutoff time.Time) (int64, error) {
+	result := DB.Model(&DataExport{}).
+		Where("status IN ?", []int{ExportPending, ExportProcessing}).
+		Where("created_at < ?", cutoff).
+		Updates(map[string]interface{}{
+			"Status":      ExportFailed,
+			"CompletedAt": time.Now(),
+		})
+
+	return result.RowsAffected, result.Error
+}
+
+// RemoveExpiredDataExportFiles deletes the archives of expired exports from
+// disk. The rows are kept, without a file, so the user still sees the export
+// as expired.
+func RemoveExpiredDataExportFiles() (int64, error) {
+	var dataExports []DataExport
+
+	err := DB.Where("file_path <> '' AND expires_at < ?", time.Now()).Find(&dataExports).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, dataExport := range dataExports {
+		if err := removeDataExportFile(dataExport.FilePath); err != nil {
+			return removed, err
+		}
+
+		if err := DB.Model(&dataExport).Update("file_path", "").Error; err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+func getUserDataExportFiles(userId uint) ([]string, error) {
+	var filePaths []string
+
+	err := DB.Model(&DataExport{}).
+		Where("user_id = ? AND file_path <> ''", userId).
+		Pluck("file_path", &filePaths).Error
+
+	return filePaths, err
+}
+
+func removeDataExportFile(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func dataExportStaleAfter() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("DATA_EXPORT_STALE_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = defaultDataExportStaleMinutes
+	}
+
+	return time.Duration(minutes) * time.Minute
+}
+
+func (d *DataExport) IsDownloadable() bool {
+	return d.Status == ExportReady && d.ExpiresAt != nil && d.ExpiresAt.After(time.Now())
+}
+
+func (d *DataExport) MarkProcessing() error {
+	d.Status = ExportProcessing
+	return DB.Model(d).Update("status", ExportProcessing).Error
+}
+
+func (d *DataExport) MarkReady(filePath string, lifespan time.Duration) error {
+	now := time.Now()
+	expiresAt := now.Add(lifespan)
+
+	d.Status = ExportReady
+	d.FilePath = filePath
+	d.CompletedAt = &now
+	d.ExpiresAt = &expiresAt
+
+	return DB.Model(d).Updates(map[string]interface{}{
+		"Status":      ExportReady,
+		"FilePath":    filePath,
+		"CompletedAt": now,
+		"ExpiresAt":   expiresAt,
+	}).Error
+}
+
+func (d *DataExport) MarkFailed() error {
+	now := time.Now()
+
+	d.Status = ExportFailed
+	d.CompletedAt = &now
+
+	return DB.Model(d).Updates(map[string]interface{}{
+		"Status":      ExportFailed,
+		"CompletedAt": now,
+	}).Error
+}
+
+func BuildUserDataArchive(userId uint) (UserDataArchive, error) {
+	archive := UserDataArchive{GeneratedAt: time.Now().UTC()}
+
+	var user User
+	if err := DB.Preload("Major").Preload("Images").First(&user, userId).Error; err != nil {
+		return archive, err
+	}
+
+	archive.User = ExportedUser{
+		ID:                user.ID,
+		Email:             user.Email,
+		Name:              user.Name,
+		PhoneNumber:       user.PhoneNumber,
+		Bio:               user.Bio,
+		Major:             user.Major.Name,
+		ProfilePictureURL: user.ProfilePictureURL,
+	}
+
+	archive.ImageURLs = make([]string, 0, len(user.Images)+1)
+	if user.ProfilePictureURL != "" {
+		archive.ImageURLs = append(archive.ImageURLs, user.ProfilePictureURL)
+	}
+	for _, image := range user.Images {
+		archive.ImageURLs = append(archive.ImageURLs, image.ImageUrl)
+	}
+
+	archive.QuizAnswers = make([]ExportedQuizAnswer, 0)
+	err := DB.Table("user_responses as ur").
+		Select("ur.question_id, q.text as question, ur.option_id, o.text as `option`").
+		Joins("LEFT JOIN question_tables q ON q.id = ur.question_id").
+		Joins("LEFT JOIN option_tables o ON o.id = ur.option_id").
+		Where("ur.user_id = ?", userId).
+		Order("ur.question_id").
+		Scan(&archive.QuizAnswers).Error
+	if err != nil {
+		return archive, err
+	}
+
+	archive.ConnectionRequests = make([]ConnectionRequest, 0)
+	err = DB.Where("requesting_user_id = ? OR requested_user_id = ?", userId, userId).
+		Order("created_at").
+		Find(&archive.ConnectionRequests).Error
+	if err != nil {
+		return archive, err
+	}
+
+	archive.Connections = make([]Connection, 0)
+	err = DB.Where("user_a = ? OR user_b = ?", userId, userId).
+		Order("created_at").
+		Find(&archive.Connections).Error
+	if err != nil {
+		return archive, err
+	}
+
+	archive.Messages = make([]Message, 0)
+	err = DB.Where("sender_id = ? OR receiver_id = ?", userId, userId).
+		Order("created_at, id").
+		Find(&archive.Messages).Error
+
+	return archive, err
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeviceToken is a Web Push subscription of one of the user's browsers or
+// devices. Endpoint is the push service URL, P256dh and Auth are the keys used
+// to encrypt the payload for it. It lives as long as the session it was
+// registered from.
+type DeviceToken struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	SessionID uint      `gorm:"not null;index" json:"-"`
+	Endpoint  string    `gorm:"size:500;not null;uniqueIndex" json:"endpoint"`
+	P256dh    string    `gorm:"size:255;not null" json:"-"`
+	Auth      string    `gorm:"size:255;not null" json:"-"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// SaveDeviceToken registers the subscription for the user's session. A
+// browser keeps its endpoint when another account logs in on it, so an
+// existing endpoint is moved to the new session instead of being duplicated.
+func SaveDeviceToken(userId uint, sessionId uint, endpoint string, p256dh string, auth string, userAgent string) (DeviceToken, error) {
+	var deviceToken DeviceToken
+
+	err := DB.Where("endpoint = ?", endpoint).First(&deviceToken).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return deviceToken, err
+	}
+
+	deviceToken.UserID = userId
+	deviceToken.SessionID = sessionId
+	deviceToken.Endpoint = endpoint
+	deviceToken.P256dh = p256dh
+	deviceToken.Auth = auth
+	deviceToken.UserAgent = userAgent
+
+	err = DB.Omit(clause.Associations).Save(&deviceToken).Error
+	return deviceToken, err
+}
+
+func GetUserDeviceTokens(userId uint) ([]DeviceToken, error) {
+	deviceTokens := make([]DeviceToken, 0)
+	err := DB.Where("user_id = ?", userId).Order("created_at").Find(&deviceTokens).Error
+	return deviceTokens, err
+}
+
+// GetPushDeviceTokens returns the subscriptions the user's pushes go to, the
+// ones registered from a session that is still active.
+func GetPushDeviceTokens(userId uint) ([]DeviceToken, error) {
+	deviceTokens := make([]DeviceToken, 0)
+	err := DB.Joins("JOIN sessions ON sessions.id = device_tokens.session_id").
+		Where("device_tokens.user_id = ?", userId).
+		Where("sessions.revoked_at IS NULL AND sessions.expires_at > ?", time.Now().UTC()).
+		Order("device_tokens.created_at").
+		Find(&deviceTokens).Error
+	return deviceTokens, err
+}
+
+func DeleteUserDeviceToken(deviceTokenId uint, userId uint) (bool, error) {
+	result := DB.Where("id = ? AND user_id = ?", deviceTokenId, userId).Delete(&DeviceToken{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteDeviceTokenByEndpoint removes a subscription the push service reported
+// as expired or unsubscribed.
+func DeleteDeviceTokenByEndpoint(endpoint string) error {
+	return DB.Where("endpoint = ?", endpoint).Delete(&DeviceToken{}).Error
+}
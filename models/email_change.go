@@ -0,0 +1,102 @@
+package models
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const MaxEmailChangeAttempts = 5
+
+// EmailChange is a pending change of a user's email, created only after the
+// user confirmed their current password, so the code can't be confirmed by
+// anyone else or borrowed from the signup verification flow.
+type EmailChange struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	UserID           uint      `gorm:"not null;index"`
+	Email            string    `gorm:"size:100;not null"`
+	VerificationCode int       `gorm:"not null"`
+	Attempts         int       `gorm:"default:0"`
+	Used             bool      `gorm:"default:false"`
+	CreatedAt        time.Time `gorm:"autoCreateTime"`
+	Expiration       time.Time `gorm:"not null"`
+}
+
+func SaveEmailChangeCode(userID uint, email string, code int) (*EmailChange, error) {
+	lifespan, err := strconv.Atoi(os.Getenv("VERIFICATION_CODE_LIFESPAN_MINUTES"))
+	if err != nil {
+		lifespan = 5
+	}
+
+	emailChange := &EmailChange{
+		UserID:           userID,
+		Email:            strings.TrimSpace(email),
+		VerificationCode: code,
+		Expiration:       time.Now().Add(time.Duration(lifespan) * time.Minute).UTC().Truncate(time.Second),
+	}
+
+	if err := DB.Create(emailChange).Error; err != nil {
+		return &EmailChange{}, err
+	}
+
+	return emailChange, nil
+}
+
+func GetValidEmailChange(userID uint, email string) (*EmailChange, error) {
+	var emailChange EmailChange
+
+	err := DB.Where("user_id = ? AND email = ? AND used = ? AND attempts < ?", userID, strings.TrimSpace(email), false, MaxEmailChangeAttempts).
+		Where("expiration >= ?", time.Now().UTC()).
+		Order("created_at DESC, id DESC").
+		First(&emailChange).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &emailChange, nil
+}
+
+func GetLatestEmailChange(userID uint, email string) (*EmailChange, error) {
+	var emailChange EmailChange
+
+	err := DB.Where("user_id = ? AND email = ? AND used = ?", userID, strings.TrimSpace(email), false).
+		Order("created_at DESC, id DESC").
+		First(&emailChange).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &emailChange, nil
+}
+
+func (e *EmailChange) IsValid(code int) bool {
+	return !e.Used &&
+		e.Attempts < MaxEmailChangeAttempts &&
+		e.Expiration.After(time.Now().UTC()) &&
+		e.VerificationCode == code
+}
+
+func (e *EmailChange) RegisterFailedAttempt() error {
+	return DB.Model(e).Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// Discard removes a code that never reached the user, so they can ask for a
+// new one right away.
+func (e *EmailChange) Discard() error {
+	return DB.Delete(e).Error
+}
+
+func ConfirmUserEmailChange(user *User, emailChange *EmailChange) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(emailChange).Update("used", true).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(user).UpdateColumn("email", emailChange.Email).Error
+	})
+}
@@ -1,5 +1,12 @@
 package models
 
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
 type EmailDomains struct {
 	ID          uint   `json:"id" gorm:"primaryKey"`
 	Institution string `json:"institution" gorm:"size:255;not null;unique"`
@@ -22,3 +29,92 @@ func EmailDomainExists(domain string) bool {
 	DB.Model(&EmailDomains{}).Where("domain = ?", domain).Count(&count)
 	return count > 0
 }
+
+var ErrEmailDomainExists = errors.New("this domain or institution is already registered")
+
+func CreateEmailDomain(adminId uint, institution string, domain string) (EmailDomains, error) {
+	emailDomain := EmailDomains{
+		Institution: strings.TrimSpace(institution),
+		Domain:      normalizeDomain(domain),
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if emailDomainTaken(tx, emailDomain, 0) {
+			return ErrEmailDomainExists
+		}
+
+		if err := tx.Create(&emailDomain).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditEmailDomainCreated, AuditTargetEmailDomain, emailDomain.ID, AuditDetails{
+			"institution": emailDomain.Institution,
+			"domain":      emailDomain.Domain,
+		})
+	})
+
+	return emailDomain, err
+}
+
+func UpdateEmailDomain(adminId uint, emailDomainId uint, institution string, domain string) (EmailDomains, error) {
+	var emailDomain EmailDomains
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&emailDomain, emailDomainId).Error; err != nil {
+			return err
+		}
+
+		previous := emailDomain
+		emailDomain.Institution = strings.TrimSpace(institution)
+		emailDomain.Domain = normalizeDomain(domain)
+
+		if emailDomainTaken(tx, emailDomain, emailDomain.ID) {
+			return ErrEmailDomainExists
+		}
+
+		if err := tx.Save(&emailDomain).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditEmailDomainUpdated, AuditTargetEmailDomain, emailDomain.ID, AuditDetails{
+			"institution":          emailDomain.Institution,
+			"domain":               emailDomain.Domain,
+			"previous_institution": previous.Institution,
+			"previous_domain":      previous.Domain,
+		})
+	})
+
+	return emailDomain, err
+}
+
+// DeleteEmailDomain only stops new registrations from the domain, existing
+// accounts are kept.
+func DeleteEmailDomain(adminId uint, emailDomainId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var emailDomain EmailDomains
+		if err := tx.First(&emailDomain, emailDomainId).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&emailDomain).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditEmailDomainDeleted, AuditTargetEmailDomain, emailDomain.ID, AuditDetails{
+			"institution": emailDomain.Institution,
+			"domain":      emailDomain.Domain,
+		})
+	})
+}
+
+func emailDomainTaken(tx *gorm.DB, emailDomain EmailDomains, exceptId uint) bool {
+	var count int64
+	tx.Model(&EmailDomains{}).
+		Where("(LOWER(domain) = ? OR LOWER(institution) = LOWER(?)) AND id <> ?", emailDomain.Domain, emailDomain.Institution, exceptId).
+		Count(&count)
+	return count > 0
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@")
+}
@@ -1,5 +1,12 @@
 package models
 
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
 type Major struct {
 	ID   uint   `json:"id" gorm:"primaryKey"`
 	Name string `json:"name" gorm:"size:255;not null;unique"`
@@ -16,3 +23,86 @@ func GetMajors() ([]Major, error) {
 	return majors, nil
 
 }
+
+func MajorExists(id uint) bool {
+	var count int64
+	DB.Model(&Major{}).Where("id = ?", id).Count(&count)
+	return count > 0
+}
+
+var (
+	ErrMajorExists = errors.New("a major with this name already exists")
+	ErrMajorInUse  = errors.New("major is used by users and can not be deleted")
+)
+
+func CreateMajor(adminId uint, name string) (Major, error) {
+	major := Major{Name: strings.TrimSpace(name)}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if majorNameTaken(tx, major.Name, 0) {
+			return ErrMajorExists
+		}
+
+		if err := tx.Create(&major).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditMajorCreated, AuditTargetMajor, major.ID, AuditDetails{"name": major.Name})
+	})
+
+	return major, err
+}
+
+func UpdateMajor(adminId uint, majorId uint, name string) (Major, error) {
+	var major Major
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&major, majorId).Error; err != nil {
+			return err
+		}
+
+		name = strings.TrimSpace(name)
+		if majorNameTaken(tx, name, major.ID) {
+			return ErrMajorExists
+		}
+
+		previousName := major.Name
+		if err := tx.Model(&major).Update("name", name).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditMajorUpdated, AuditTargetMajor, major.ID, AuditDetails{"name": name, "previous_name": previousName})
+	})
+
+	return major, err
+}
+
+func DeleteMajor(adminId uint, majorId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var major Major
+		if err := tx.First(&major, majorId).Error; err != nil {
+			return err
+		}
+
+		var users int64
+		if err := tx.Model(&User{}).Where("major_id = ?", majorId).Count(&users).Error; err != nil {
+			return err
+		}
+
+		if users > 0 {
+			return ErrMajorInUse
+		}
+
+		if err := tx.Delete(&major).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditMajorDeleted, AuditTargetMajor, major.ID, AuditDetails{"name": major.Name})
+	})
+}
+
+func majorNameTaken(tx *gorm.DB, name string, exceptId uint) bool {
+	var count int64
+	tx.Model(&Major{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, exceptId).Count(&count)
+	return count > 0
+}
@@ -1,23 +1,144 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxMessageContentLength = 2000
+	DefaultMessagePageSize  = 50
+	MaxMessagePageSize      = 100
+)
+
+var (
+	ErrEmptyMessage   = errors.New("message content can not be empty")
+	ErrMessageTooLong = errors.New("message content is too long")
+)
 
 type Message struct {
-    ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-    ConnectionID uint      `gorm:"not null;index" json:"connection_id"`
-    Connection   Connection `gorm:"foreignKey:ConnectionID;constraint:OnDelete:CASCADE" json:"-"`
-    SenderID     uint      `gorm:"not null;index" json:"sender_id"`
-    Sender       User      `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE" json:"-"`
-	ReceiverID   uint      `gorm:"not null;index" json:"receiver_id"`
-    Receiver     User      `gorm:"foreignKey:ReceiverID;constraint:OnDelete:CASCADE" json:"-"`
-    Content      string    `gorm:"type:text;not null" json:"content"`
-    CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-    ReadAt       *time.Time `json:"read_at"`
-}
-
-func GetMessages(connectionId uint) ([]Message, error) {
-    var results []Message
-
-    err := DB.Where("connection_id = ?", connectionId).Order("created_at asc").Find(&results).Error;
-    return results, err
-}
\ No newline at end of file
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ConnectionID uint       `gorm:"not null;index;index:idx_messages_connection_created,priority:1" json:"connection_id"`
+	Connection   Connection `gorm:"foreignKey:ConnectionID;constraint:OnDelete:CASCADE" json:"-"`
+	SenderID     uint       `gorm:"not null;index" json:"sender_id"`
+	Sender       User       `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE" json:"-"`
+	ReceiverID   uint       `gorm:"not null;index:idx_messages_receiver_unread,priority:1" json:"receiver_id"`
+	Receiver     User       `gorm:"foreignKey:ReceiverID;constraint:OnDelete:CASCADE" json:"-"`
+	Content      string     `gorm:"type:text;not null" json:"content"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime;index:idx_messages_connection_created,priority:2" json:"created_at"`
+	ReadAt       *time.Time `gorm:"index:idx_messages_receiver_unread,priority:2" json:"read_at"`
+}
+
+// MessageCursor selects one page of a conversation. Before pages backwards
+// from a message id, After pages forwards; with neither set the latest page is
+// returned.
+type MessageCursor struct {
+	Before uint
+	After  uint
+	Limit  int
+}
+
+// GetMessages returns a page of messages in ascending order and whether more
+// messages exist past the page in the direction being paged.
+func GetMessages(connectionId uint, cursor MessageCursor) ([]Message, bool, error) {
+	var results []Message
+
+	limit := cursor.Limit
+	if limit <= 0 {
+		limit = DefaultMessagePageSize
+	} else if limit > MaxMessagePageSize {
+		limit = MaxMessagePageSize
+	}
+
+	query := DB.Where("connection_id = ?", connectionId)
+
+	if cursor.After > 0 {
+		query = query.Where("id > ?", cursor.After).Order("id asc")
+	} else {
+		if cursor.Before > 0 {
+			query = query.Where("id < ?", cursor.Before)
+		}
+		query = query.Order("id desc")
+	}
+
+	if err := query.Limit(limit + 1).Find(&results).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	if cursor.After == 0 {
+		for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+			results[i], results[j] = results[j], results[i]
+		}
+	}
+
+	return results, hasMore, nil
+}
+
+func MessageInConnection(messageId uint, connectionId uint) bool {
+	var count int64
+
+	err := DB.Model(&Message{}).
+		Where("id = ? AND connection_id = ?", messageId, connectionId).
+		Count(&count).Error
+
+	return err == nil && count > 0
+}
+
+// MarkMessagesRead sets ReadAt on every unread message the reader received in
+// the connection up to and including upToMessageId.
+func MarkMessagesRead(connectionId uint, readerId uint, upToMessageId uint) (int64, time.Time, error) {
+	readAt := time.Now().UTC()
+
+	result := DB.Model(&Message{}).
+		Where("connection_id = ? AND receiver_id = ? AND id <= ? AND read_at IS NULL", connectionId, readerId, upToMessageId).
+		Update("read_at", readAt)
+
+	return result.RowsAffected, readAt, result.Error
+}
+
+// CountUnreadMessages counts the messages the user received and has not read
+// yet, ignoring those sent by deactivated or deleted accounts.
+func CountUnreadMessages(userId uint) (int64, error) {
+	var count int64
+
+	err := DB.Table("messages as m").
+		Joins("JOIN users u ON u.id = m.sender_id").
+		Where("m.receiver_id = ? AND m.read_at IS NULL", userId).
+		Where("u.status = 1 AND u.deleted_at IS NULL").
+		Count(&count).Error
+
+	return count, err
+}
+
+func ValidateMessageContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+
+	if content == "" {
+		return "", ErrEmptyMessage
+	}
+
+	if utf8.RuneCountInString(content) > MaxMessageContentLength {
+		return "", ErrMessageTooLong
+	}
+
+	return content, nil
+}
+
+func CreateMessage(connection Connection, senderId uint, content string) (Message, error) {
+	message := Message{
+		ConnectionID: connection.ID,
+		SenderID:     senderId,
+		ReceiverID:   connection.OtherUserID(senderId),
+		Content:      content,
+	}
+
+	err := DB.Create(&message).Error
+	return message, err
+}
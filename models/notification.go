@@ -0,0 +1,141 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	NotificationConnectionRequestReceived = "connection_request.received"
+	NotificationConnectionRequestAccepted = "connection_request.accepted"
+
+	NotificationTargetConnectionRequest = "connection_request"
+	NotificationTargetConnection        = "connection"
+
+	DefaultNotificationPageSize = 20
+	MaxNotificationPageSize     = 50
+)
+
+// Notification tells UserID that ActorID did something. Target points at the
+// row the notification is about, e.g. the connection request that was sent.
+type Notification struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint       `gorm:"not null;index:idx_notifications_user_read,priority:1" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Type       string     `gorm:"size:50;not null" json:"type"`
+	ActorID    uint       `gorm:"not null;index" json:"actor_id"`
+	Actor      User       `gorm:"foreignKey:ActorID;constraint:OnDelete:CASCADE" json:"-"`
+	TargetType string     `gorm:"size:50;not null" json:"target_type"`
+	TargetID   uint       `gorm:"not null" json:"target_id"`
+	ReadAt     *time.Time `gorm:"index:idx_notifications_user_read,priority:2" json:"read_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type NotificationWithActor struct {
+	Notification           `gorm:"embedded"`
+	ActorName              string `json:"actor_name"`
+	ActorProfilePictureURL string `json:"actor_profile_picture_url"`
+}
+
+// NotificationCursor selects one page of notifications, newest first. Before
+// pages backwards from a notification id.
+type NotificationCursor struct {
+	Before     uint
+	Limit      int
+	UnreadOnly bool
+}
+
+func CreateNotification(userId uint, notificationType string, actorId uint, targetType string, targetId uint) (NotificationWithActor, error) {
+	notification := Notification{
+		UserID:     userId,
+		Type:       notificationType,
+		ActorID:    actorId,
+		TargetType: targetType,
+		TargetID:   targetId,
+	}
+
+	if err := DB.Omit(clause.Associations).Create(&notification).Error; err != nil {
+		return NotificationWithActor{}, err
+	}
+
+	var result NotificationWithActor
+	err := notificationsWithActor().Where("n.id = ?", notification.ID).Scan(&result).Error
+	return result, err
+}
+
+// GetNotifications returns a page of the user's notifications and whether
+// older ones exist past it. Notifications from a user either side blocked are
+// left out.
+func GetNotifications(userId uint, cursor NotificationCursor) ([]NotificationWithActor, bool, error) {
+	results := make([]NotificationWithActor, 0)
+
+	limit := cursor.Limit
+	if limit <= 0 || limit > MaxNotificationPageSize {
+		limit = DefaultNotificationPageSize
+	}
+
+	query := notificationsWithActor().
+		Where("n.user_id = ?", userId).
+		Where(notBlocked("n.actor_id"), userId, userId)
+
+	if cursor.Before > 0 {
+		query = query.Where("n.id < ?", cursor.Before)
+	}
+
+	if cursor.UnreadOnly {
+		query = query.Where("n.read_at IS NULL")
+	}
+
+	if err := query.Order("n.id desc").Limit(limit + 1).Scan(&results).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	return results, hasMore, nil
+}
+
+func CountUnreadNotifications(userId uint) (int64, error) {
+	var count int64
+	err := DB.Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userId).
+		Where(notBlocked("actor_id"), userId, userId).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkNotificationRead reports false when the notification does not belong to
+// the user. Marking an already read notification again keeps its first ReadAt.
+func MarkNotificationRead(notificationId uint, userId uint) (bool, error) {
+	var notification Notification
+	result := DB.Where("id = ? AND user_id = ?", notificationId, userId).Limit(1).Find(&notification)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	if notification.ReadAt != nil {
+		return true, nil
+	}
+
+	err := DB.Model(&notification).Update("read_at", time.Now().UTC()).Error
+	return err == nil, err
+}
+
+func MarkAllNotificationsRead(userId uint) (int64, error) {
+	result := DB.Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userId).
+		Update("read_at", time.Now().UTC())
+
+	return result.RowsAffected, result.Error
+}
+
+func notificationsWithActor() *gorm.DB {
+	return DB.Table("notifications as n").
+		Select("n.*, u.name as actor_name, u.profile_picture_url as actor_profile_picture_url").
+		Joins("JOIN users u ON u.id = n.actor_id")
+}
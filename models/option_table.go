@@ -1,9 +1,16 @@
 package models
 
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
 type OptionTable struct {
 	ID            uint          `json:"option_id" gorm:"primaryKey"`
 	Text          string        `json:"text" gorm:"size:255;not null"`
 	QuestionID    uint          `json:"question_id"`
+	Position      int           `json:"position" gorm:"not null;default:0"`
 	QuestionTable QuestionTable `gorm:"foreignKey:QuestionID"`
 }
 
@@ -23,10 +30,140 @@ func GetOptions() ([]OptionTable, error) {
 
 	var options []OptionTable
 
-	if err := DB.Find(&options).Error; err != nil {
+	if err := DB.Order("position, id").Find(&options).Error; err != nil {
 		return options, err
 	}
 
 	return options, nil
 
 }
+
+func CreateOption(adminId uint, questionId uint, text string) (OptionTable, error) {
+	option := OptionTable{Text: strings.TrimSpace(text), QuestionID: questionId}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var question QuestionTable
+		if err := tx.Select("id", "quiz_id").First(&question, questionId).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&OptionTable{}).Where("question_id = ?", questionId).
+			Select("COALESCE(MAX(position), 0) + 1").Scan(&option.Position).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Omit("QuestionTable").Create(&option).Error; err != nil {
+			return err
+		}
+
+		if err := bumpQuizVersion(tx, question.Quiz_id); err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditOptionCreated, AuditTargetOption, option.ID, AuditDetails{
+			"question_id": questionId,
+			"text":        option.Text,
+		})
+	})
+
+	return option, err
+}
+
+func UpdateOption(adminId uint, optionId uint, text string) (OptionTable, error) {
+	var option OptionTable
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&option, optionId).Error; err != nil {
+			return err
+		}
+
+		previousText := option.Text
+		if err := tx.Model(&option).Update("text", strings.TrimSpace(text)).Error; err != nil {
+			return err
+		}
+
+		var question QuestionTable
+		if err := tx.Select("id", "quiz_id").First(&question, option.QuestionID).Error; err != nil {
+			return err
+		}
+
+		if err := bumpQuizVersion(tx, question.Quiz_id); err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditOptionUpdated, AuditTargetOption, option.ID, AuditDetails{
+			"text":          option.Text,
+			"previous_text": previousText,
+		})
+	})
+
+	return option, err
+}
+
+func DeleteOption(adminId uint, optionId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var option OptionTable
+		if err := tx.First(&option, optionId).Error; err != nil {
+			return err
+		}
+
+		var answers int64
+		if err := tx.Model(&UserResponse{}).Where("option_id = ?", optionId).Count(&answers).Error; err != nil {
+			return err
+		}
+
+		if answers > 0 {
+			return ErrQuizAnswered
+		}
+
+		if err := tx.Delete(&option).Error; err != nil {
+			return err
+		}
+
+		var question QuestionTable
+		if err := tx.Select("id", "quiz_id").First(&question, option.QuestionID).Error; err != nil {
+			return err
+		}
+
+		if err := bumpQuizVersion(tx, question.Quiz_id); err != nil {
+			return err
+		}
+
+		if err := validatePublishedQuiz(tx, question.Quiz_id); err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditOptionDeleted, AuditTargetOption, option.ID, AuditDetails{
+			"question_id": option.QuestionID,
+			"text":        option.Text,
+		})
+	})
+}
+
+// ReorderOptions sets the order of the question's options to the one of
+// optionIds, which must list each of them once.
+func ReorderOptions(adminId uint, questionId uint, optionIds []uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&QuestionTable{}, questionId).Error; err != nil {
+			return err
+		}
+
+		var currentIds []uint
+		if err := tx.Model(&OptionTable{}).Where("question_id = ?", questionId).Pluck("id", &currentIds).Error; err != nil {
+			return err
+		}
+
+		if !sameIds(currentIds, optionIds) {
+			return ErrInvalidContentOrder
+		}
+
+		for i, optionId := range optionIds {
+			if err := tx.Model(&OptionTable{}).Where("id = ?", optionId).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+
+		return RecordAudit(tx, adminId, AuditOptionsReordered, AuditTargetQuestion, questionId, AuditDetails{"option_ids": optionIds})
+	})
+}
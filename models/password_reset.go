@@ -0,0 +1,104 @@
+package models
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const MaxPasswordResetAttempts = 5
+
+type PasswordReset struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Email      string    `gorm:"size:100;not null;index"`
+	ResetCode  int       `gorm:"not null"`
+	Attempts   int       `gorm:"default:0"`
+	Used       bool      `gorm:"default:false"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	Expiration time.Time `gorm:"not null"`
+}
+
+func SavePasswordResetCode(email string, code int) (*PasswordReset, error) {
+	lifespan, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_CODE_LIFESPAN_MINUTES"))
+	if err != nil {
+		lifespan = 15
+	}
+
+	passwordReset := &PasswordReset{
+		Email:      email,
+		ResetCode:  code,
+		Expiration: time.Now().Add(time.Duration(lifespan) * time.Minute).UTC().Truncate(time.Second),
+	}
+
+	if err := DB.Create(passwordReset).Error; err != nil {
+		return &PasswordReset{}, err
+	}
+
+	return passwordReset, nil
+}
+
+func HasValidPasswordResetCode(email string) bool {
+	var count int64
+
+	err := DB.Model(&PasswordReset{}).
+		Where("email = ? AND used = ? AND attempts < ?", email, false, MaxPasswordResetAttempts).
+		Where("expiration >= ?", time.Now().UTC()).
+		Count(&count).Error
+
+	if err != nil {
+		return false
+	}
+
+	return count > 0
+}
+
+func GetLatestPasswordResetCode(email string) (*PasswordReset, error) {
+	var passwordReset PasswordReset
+
+	err := DB.Where("email = ? AND used = ?", email, false).
+		Order("created_at DESC, id DESC").
+		First(&passwordReset).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &passwordReset, nil
+}
+
+func (p *PasswordReset) IsValid(code int) bool {
+	return !p.Used &&
+		p.Attempts < MaxPasswordResetAttempts &&
+		p.Expiration.After(time.Now().UTC()) &&
+		p.ResetCode == code
+}
+
+func (p *PasswordReset) RegisterFailedAttempt() error {
+	return DB.Model(p).Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// Discard removes a code that never reached the user, so they can ask for a
+// new one right away.
+func (p *PasswordReset) Discard() error {
+	return DB.Delete(p).Error
+}
+
+func ResetUserPassword(user *User, newPassword string, passwordReset *PasswordReset) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := user.SetPassword(newPassword); err != nil {
+			return err
+		}
+
+		if err := tx.Model(user).UpdateColumn("password", user.Password).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(passwordReset).Update("used", true).Error; err != nil {
+			return err
+		}
+
+		return revokeSessions(tx, "user_id = ?", user.ID)
+	})
+}
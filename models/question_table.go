@@ -2,14 +2,17 @@ package models
 
 import (
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 type QuestionTable struct {
-	ID      uint   `json:"id" gorm:"primaryKey"`
-	Text    string `json:"text" gorm:"size:255;not null"`
-	Quiz_id uint   `json:"quizId"`
-	Quiz    QuizTable
-	Options []OptionTable `gorm:"foreignKey:QuestionID"`
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Text     string `json:"text" gorm:"size:255;not null"`
+	Quiz_id  uint   `json:"quizId"`
+	Position int    `json:"position" gorm:"not null;default:0"`
+	Quiz     QuizTable
+	Options  []OptionTable `gorm:"foreignKey:QuestionID"`
 }
 
 func GetQuestionByID(id uint) (QuestionTable, error) {
@@ -28,7 +31,15 @@ func GetQuestions() ([]QuestionTable, error) {
 
 	var questions []QuestionTable
 
-	if err := DB.Model(&QuestionTable{}).Preload("Options").Find(&questions).Error; err != nil {
+	err := DB.Model(&QuestionTable{}).
+		Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position, id")
+		}).
+		Joins("JOIN quiz_tables ON quiz_tables.id = question_tables.quiz_id").
+		Where("quiz_tables.status = ?", QuizStatusPublished).
+		Order("question_tables.quiz_id, question_tables.position, question_tables.id").
+		Find(&questions).Error
+	if err != nil {
 		return questions, err
 	}
 
@@ -36,25 +47,124 @@ func GetQuestions() ([]QuestionTable, error) {
 
 }
 
-func GetQuestionsAndOptions(userAnswers []OptionTable) ([]OptionTable, error) {
+// GetQuizQuestions returns the questions of one quiz with their options, in
+// the order they are shown.
+func GetQuizQuestions(quizId uint) ([]QuestionTable, error) {
+	questions := make([]QuestionTable, 0)
+
+	err := DB.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Where("quiz_id = ?", quizId).Order("position, id").Find(&questions).Error
+	if err != nil {
+		return nil, err
+	}
 
-	var optionsAndQuestions []OptionTable
+	return questions, nil
+}
 
-	var queryConditions strings.Builder
-	var queryArgs []interface{}
+// CreateQuestion adds a question to the end of the quiz together with its
+// options. A published quiz only takes questions with enough options.
+func CreateQuestion(adminId uint, quizId uint, text string, options []string) (QuestionTable, error) {
+	question := QuestionTable{Text: strings.TrimSpace(text), Quiz_id: quizId}
 
-	for i, answer := range userAnswers  {
-		if i > 0 {
-			queryConditions.WriteString(" OR ")
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&QuizTable{}, quizId).Error; err != nil {
+			return err
 		}
-		queryConditions.WriteString("(question_id = ? AND id = ?)")
-		queryArgs = append(queryArgs, answer.QuestionID, answer.ID)
-	}
 
-	if err := DB.Model(&OptionTable{}).Where(queryConditions.String(), queryArgs...).Find(&optionsAndQuestions).Error; err != nil {
-		return optionsAndQuestions, err
-	}
+		err := tx.Model(&QuestionTable{}).Where("quiz_id = ?", quizId).
+			Select("COALESCE(MAX(position), 0) + 1").Scan(&question.Position).Error
+		if err != nil {
+			return err
+		}
+
+		for i, option := range options {
+			question.Options = append(question.Options, OptionTable{Text: strings.TrimSpace(option), Position: i + 1})
+		}
+
+		if err := tx.Omit("Quiz").Create(&question).Error; err != nil {
+			return err
+		}
+
+		if err := bumpQuizVersion(tx, quizId); err != nil {
+			return err
+		}
+
+		if err := validatePublishedQuiz(tx, quizId); err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditQuestionCreated, AuditTargetQuestion, question.ID, AuditDetails{
+			"quiz_id": quizId,
+			"text":    question.Text,
+			"options": len(question.Options),
+		})
+	})
 
-	return optionsAndQuestions, nil
+	return question, err
+}
+
+func UpdateQuestion(adminId uint, questionId uint, text string) (QuestionTable, error) {
+	var question QuestionTable
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&question, questionId).Error; err != nil {
+			return err
+		}
+
+		previousText := question.Text
+		if err := tx.Model(&question).Update("text", strings.TrimSpace(text)).Error; err != nil {
+			return err
+		}
+
+		if err := bumpQuizVersion(tx, question.Quiz_id); err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditQuestionUpdated, AuditTargetQuestion, question.ID, AuditDetails{
+			"text":          question.Text,
+			"previous_text": previousText,
+		})
+	})
+
+	return question, err
+}
+
+func DeleteQuestion(adminId uint, questionId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var question QuestionTable
+		if err := tx.First(&question, questionId).Error; err != nil {
+			return err
+		}
+
+		var answers int64
+		if err := tx.Model(&UserResponse{}).Where("question_id = ?", questionId).Count(&answers).Error; err != nil {
+			return err
+		}
+
+		if answers > 0 {
+			return ErrQuizAnswered
+		}
+
+		if err := tx.Where("question_id = ?", questionId).Delete(&OptionTable{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&question).Error; err != nil {
+			return err
+		}
+
+		if err := bumpQuizVersion(tx, question.Quiz_id); err != nil {
+			return err
+		}
+
+		if err := validatePublishedQuiz(tx, question.Quiz_id); err != nil {
+			return err
+		}
 
+		return RecordAudit(tx, adminId, AuditQuestionDeleted, AuditTargetQuestion, question.ID, AuditDetails{
+			"quiz_id": question.Quiz_id,
+			"text":    question.Text,
+		})
+	})
 }
@@ -0,0 +1,330 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrIncompleteAnswers = errors.New("answers must cover every question of the quiz once")
+
+const DefaultQuizRetakeCooldownHours = 24
+
+// RetakeCooldownError is returned when a user changes their answers to a quiz
+// again before the cooldown since the last change is over.
+type RetakeCooldownError struct {
+	NextRetakeAt time.Time
+}
+
+func (e *RetakeCooldownError) Error() string {
+	return "quiz answers can be changed again after " + e.NextRetakeAt.Format(time.RFC3339)
+}
+
+// QuizCompletion records that a user answered every question of a quiz, and
+// which version of the quiz that was. Any change to its questions or options
+// moves the quiz to a new version, after which the completion is outdated and
+// the quiz can be taken again.
+type QuizCompletion struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_quiz_completions_user_quiz,priority:1" json:"user_id"`
+	QuizID      uint      `gorm:"not null;uniqueIndex:idx_quiz_completions_user_quiz,priority:2;index" json:"quiz_id"`
+	QuizVersion int       `gorm:"not null" json:"quiz_version"`
+	CompletedAt time.Time `gorm:"not null" json:"completed_at"`
+	// EditedAt is the last time the user changed their answers to this version.
+	EditedAt *time.Time `gorm:"default:NULL" json:"edited_at"`
+}
+
+// QuizWithProgress is a published quiz as listed to a user.
+type QuizWithProgress struct {
+	ID            uint       `json:"id"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Version       int        `json:"version"`
+	QuestionCount int        `json:"question_count"`
+	Completed     bool       `json:"completed"`
+	CompletedAt   *time.Time `json:"completed_at"`
+}
+
+func GetPublishedQuizzes() ([]QuizTable, error) {
+	quizzes := make([]QuizTable, 0)
+
+	if err := DB.Where("status = ?", QuizStatusPublished).Order("id").Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+
+	return quizzes, nil
+}
+
+func GetPublishedQuiz(quizId uint) (QuizTable, error) {
+	var quiz QuizTable
+
+	if err := DB.Where("status = ?", QuizStatusPublished).First(&quiz, quizId).Error; err != nil {
+		return quiz, err
+	}
+
+	return quiz, nil
+}
+
+// GetQuizzesWithProgress lists the published quizzes together with whether
+// the user completed their current version.
+func GetQuizzesWithProgress(userId uint) ([]QuizWithProgress, error) {
+	quizzes, err := GetPublishedQuizzes()
+	if err != nil {
+		return nil, err
+	}
+
+	taken, err := GetTakenQuizIDs(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var completions []QuizCompletion
+	if err := DB.Where("user_id = ?", userId).Find(&completions).Error; err != nil {
+		return nil, err
+	}
+
+	completedAt := make(map[uint]time.Time, len(completions))
+	for _, completion := range completions {
+		completedAt[completion.QuizID] = completion.CompletedAt
+	}
+
+	type questionCount struct {
+		QuizID uint
+		Count  int
+	}
+
+	var counts []questionCount
+	err = DB.Model(&QuestionTable{}).Select("quiz_id, COUNT(*) as count").Group("quiz_id").Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	questionCounts := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		questionCounts[count.QuizID] = count.Count
+	}
+
+	results := make([]QuizWithProgress, 0, len(quizzes))
+	for _, quiz := range quizzes {
+		result := QuizWithProgress{
+			ID:            quiz.ID,
+			Title:         quiz.Title,
+			Description:   quiz.Description,
+			Version:       quiz.Version,
+			QuestionCount: questionCounts[quiz.ID],
+			Completed:     taken[quiz.ID],
+		}
+
+		if at, ok := completedAt[quiz.ID]; ok && result.Completed {
+			result.CompletedAt = &at
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// GetTakenQuizIDs returns the quizzes whose current version the user has
+// completed. Answers saved before completions were recorded count as a
+// completion of the quiz they belong to.
+func GetTakenQuizIDs(userId uint) (map[uint]bool, error) {
+	taken := make(map[uint]bool)
+
+	var completions []QuizCompletion
+	if err := DB.Where("user_id = ?", userId).Find(&completions).Error; err != nil {
+		return nil, err
+	}
+
+	completedVersion := make(map[uint]int, len(completions))
+	for _, completion := range completions {
+		completedVersion[completion.QuizID] = completion.QuizVersion
+	}
+
+	var answeredQuizIds []uint
+	err := DB.Model(&UserResponse{}).
+		Joins("JOIN question_tables ON question_tables.id = user_responses.question_id").
+		Where("user_responses.user_id = ?", userId).
+		Distinct().
+		Pluck("question_tables.quiz_id", &answeredQuizIds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, quizId := range answeredQuizIds {
+		if _, ok := completedVersion[quizId]; !ok {
+			taken[quizId] = true
+		}
+	}
+
+	if len(completedVersion) == 0 {
+		return taken, nil
+	}
+
+	var quizzes []QuizTable
+	if err := DB.Select("id", "version").Where("id IN ?", mapKeys(completedVersion)).Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+
+	for _, quiz := range quizzes {
+		if completedVersion[quiz.ID] == quiz.Version {
+			taken[quiz.ID] = true
+		}
+	}
+
+	return taken, nil
+}
+
+// QuizRetakeCooldown is how long a user waits between two changes to their
+// answers to the same quiz. Zero disables it.
+func QuizRetakeCooldown() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("QUIZ_RETAKE_COOLDOWN_HOURS"))
+	if err != nil || hours < 0 {
+		hours = DefaultQuizRetakeCooldownHours
+	}
+
+	return time.Duration(hours) * time.Hour
+}
+
+// GetQuizCompletion returns nil when the user has no recorded completion of
+// the quiz.
+func GetQuizCompletion(userId uint, quizId uint) (*QuizCompletion, error) {
+	return findQuizCompletion(DB, userId, quizId)
+}
+
+func findQuizCompletion(tx *gorm.DB, userId uint, quizId uint) (*QuizCompletion, error) {
+	var completions []QuizCompletion
+	if err := tx.Where("user_id = ? AND quiz_id = ?", userId, quizId).Limit(1).Find(&completions).Error; err != nil {
+		return nil, err
+	}
+
+	if len(completions) == 0 {
+		return nil, nil
+	}
+
+	return &completions[0], nil
+}
+
+// NextRetakeAt is when the user may change their answers again. The first
+// change after taking the quiz is allowed right away, so a mistake can be
+// fixed, later ones wait for the cooldown since the previous change.
+func (completion QuizCompletion) NextRetakeAt() time.Time {
+	if completion.EditedAt == nil {
+		return completion.CompletedAt
+	}
+
+	return completion.EditedAt.Add(QuizRetakeCooldown())
+}
+
+// SaveQuizAnswers stores the user's answers to every question of the quiz and
+// marks the current version as completed. Answers to an outdated version of
+// the quiz are replaced.
+func SaveQuizAnswers(userId uint, quiz QuizTable, answers []UserResponse) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return replaceQuizAnswers(tx, userId, quiz, answers, false)
+	})
+}
+
+// UpdateQuizAnswers replaces the user's answers to the quiz. Only repeated
+// changes are limited to one per cooldown. Match scores are computed from the answers on every request, so
+// they follow the new answers right away.
+func UpdateQuizAnswers(userId uint, quiz QuizTable, answers []UserResponse) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		completion, err := findQuizCompletion(tx, userId, quiz.ID)
+		if err != nil {
+			return err
+		}
+
+		if completion != nil {
+			nextRetakeAt := completion.NextRetakeAt()
+			if time.Now().UTC().Before(nextRetakeAt) {
+				return &RetakeCooldownError{NextRetakeAt: nextRetakeAt}
+			}
+		}
+
+		return replaceQuizAnswers(tx, userId, quiz, answers, true)
+	})
+}
+
+// replaceQuizAnswers marks the quiz as completed when the answers are new and
+// as edited when they change earlier ones, which keeps CompletedAt.
+func replaceQuizAnswers(tx *gorm.DB, userId uint, quiz QuizTable, answers []UserResponse, edited bool) error {
+	var questions []QuestionTable
+	if err := tx.Preload("Options").Where("quiz_id = ?", quiz.ID).Find(&questions).Error; err != nil {
+		return err
+	}
+
+	if !answersCoverQuestions(questions, answers) {
+		return ErrIncompleteAnswers
+	}
+
+	questionIds := tx.Model(&QuestionTable{}).Select("id").Where("quiz_id = ?", quiz.ID)
+
+	err := tx.Where("user_id = ? AND question_id IN (?)", userId, questionIds).Delete(&UserResponse{}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Omit(clause.Associations).Create(&answers).Error; err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	completion := QuizCompletion{
+		UserID:      userId,
+		QuizID:      quiz.ID,
+		QuizVersion: quiz.Version,
+		CompletedAt: now,
+	}
+
+	columns := []string{"quiz_version", "completed_at", "edited_at"}
+	if edited {
+		completion.EditedAt = &now
+		columns = []string{"quiz_version", "edited_at"}
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "quiz_id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&completion).Error
+}
+
+// answersCoverQuestions checks that there is exactly one answer per question
+// and that every answer picks one of its question's options.
+func answersCoverQuestions(questions []QuestionTable, answers []UserResponse) bool {
+	if len(questions) == 0 || len(answers) != len(questions) {
+		return false
+	}
+
+	options := make(map[uint]map[uint]bool, len(questions))
+	for _, question := range questions {
+		options[question.ID] = make(map[uint]bool, len(question.Options))
+		for _, option := range question.Options {
+			options[question.ID][option.ID] = true
+		}
+	}
+
+	answered := make(map[uint]bool, len(answers))
+	for _, answer := range answers {
+		if answered[answer.QuestionID] || !options[answer.QuestionID][answer.OptionID] {
+			return false
+		}
+
+		answered[answer.QuestionID] = true
+	}
+
+	return true
+}
+
+func mapKeys(m map[uint]int) []uint {
+	keys := make([]uint, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -1,12 +1,44 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	QuizStatusDraft     = "draft"
+	QuizStatusPublished = "published"
+
+	MinOptionsPerQuestion = 2
+)
+
+// QuizTable is only shown to users once published. Quizzes that predate the
+// status were filled in by hand and are live, so the column defaults to
+// published; quizzes created through the admin API start as drafts.
 type QuizTable struct {
 	ID          uint            `json:"id" gorm:"primaryKey"`
 	Title       string          `json:"title" gorm:"size:255;not null;unique"`
 	Description string          `json:"description" gorm:"size:255;not null"`
+	Status      string          `json:"status" gorm:"size:20;not null;default:published;index"`
+	Version     int             `json:"version" gorm:"not null;default:1"`
+	PublishedAt *time.Time      `json:"published_at"`
 	Questions   []QuestionTable `gorm:"foreignKey:Quiz_id"`
 }
 
+// QuizValidationError lists why a quiz can not be published, or why a change
+// would leave a published quiz incomplete.
+type QuizValidationError struct {
+	Problems []string
+}
+
+func (e *QuizValidationError) Error() string {
+	return "quiz is incomplete: " + strings.Join(e.Problems, "; ")
+}
+
 func GetQuizByID(id uint) (QuizTable, error) {
 
 	var quiz QuizTable
@@ -18,3 +50,269 @@ func GetQuizByID(id uint) (QuizTable, error) {
 	return quiz, nil
 
 }
+
+var (
+	ErrQuizTitleTaken      = errors.New("a quiz with this title already exists")
+	ErrQuizAnswered        = errors.New("quiz content was already answered and can not be deleted")
+	ErrQuizPublished       = errors.New("quiz is already published")
+	ErrQuizNotPublished    = errors.New("quiz is not published")
+	ErrInvalidContentOrder = errors.New("the new order must list every item exactly once")
+)
+
+func GetQuizzes() ([]QuizTable, error) {
+	quizzes := make([]QuizTable, 0)
+	err := DB.Order("id").Find(&quizzes).Error
+	return quizzes, err
+}
+
+// GetQuizWithQuestions loads the quiz with its questions and their options.
+func GetQuizWithQuestions(quizId uint) (QuizTable, error) {
+	var quiz QuizTable
+
+	err := DB.Preload("Questions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Preload("Questions.Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).First(&quiz, quizId).Error
+
+	return quiz, err
+}
+
+func CreateQuiz(adminId uint, title string, description string) (QuizTable, error) {
+	quiz := QuizTable{Title: strings.TrimSpace(title), Description: strings.TrimSpace(description), Status: QuizStatusDraft}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if quizTitleTaken(tx, quiz.Title, 0) {
+			return ErrQuizTitleTaken
+		}
+
+		if err := tx.Create(&quiz).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditQuizCreated, AuditTargetQuiz, quiz.ID, AuditDetails{"title": quiz.Title})
+	})
+
+	return quiz, err
+}
+
+func UpdateQuiz(adminId uint, quizId uint, title string, description string) (QuizTable, error) {
+	var quiz QuizTable
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&quiz, quizId).Error; err != nil {
+			return err
+		}
+
+		title = strings.TrimSpace(title)
+		if quizTitleTaken(tx, title, quiz.ID) {
+			return ErrQuizTitleTaken
+		}
+
+		previousTitle := quiz.Title
+		err := tx.Model(&quiz).Updates(map[string]interface{}{
+			"title":       title,
+			"description": strings.TrimSpace(description),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditQuizUpdated, AuditTargetQuiz, quiz.ID, AuditDetails{"title": title, "previous_title": previousTitle})
+	})
+
+	return quiz, err
+}
+
+// DeleteQuiz removes the quiz with its questions and options, as long as
+// nobody answered it.
+func DeleteQuiz(adminId uint, quizId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var quiz QuizTable
+		if err := tx.First(&quiz, quizId).Error; err != nil {
+			return err
+		}
+
+		questionIds := tx.Model(&QuestionTable{}).Select("id").Where("quiz_id = ?", quizId)
+
+		var answers int64
+		if err := tx.Model(&UserResponse{}).Where("question_id IN (?)", questionIds).Count(&answers).Error; err != nil {
+			return err
+		}
+
+		if answers > 0 {
+			return ErrQuizAnswered
+		}
+
+		if err := tx.Where("question_id IN (?)", questionIds).Delete(&OptionTable{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("quiz_id = ?", quizId).Delete(&QuizCompletion{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("quiz_id = ?", quizId).Delete(&QuestionTable{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&quiz).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditQuizDeleted, AuditTargetQuiz, quiz.ID, AuditDetails{"title": quiz.Title})
+	})
+}
+
+func quizTitleTaken(tx *gorm.DB, title string, exceptId uint) bool {
+	var count int64
+	tx.Model(&QuizTable{}).Where("LOWER(title) = LOWER(?) AND id <> ?", title, exceptId).Count(&count)
+	return count > 0
+}
+
+// PublishQuiz makes the quiz visible to users, provided it has questions and
+// every question has enough options to choose from.
+func PublishQuiz(adminId uint, quizId uint) (QuizTable, error) {
+	var quiz QuizTable
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&quiz, quizId).Error; err != nil {
+			return err
+		}
+
+		if quiz.Status == QuizStatusPublished {
+			return ErrQuizPublished
+		}
+
+		if err := validateQuizContent(tx, quizId); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		err := tx.Model(&quiz).Updates(map[string]interface{}{"status": QuizStatusPublished, "published_at": now}).Error
+		if err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditQuizPublished, AuditTargetQuiz, quiz.ID, AuditDetails{"title": quiz.Title})
+	})
+
+	return quiz, err
+}
+
+func UnpublishQuiz(adminId uint, quizId uint) (QuizTable, error) {
+	var quiz QuizTable
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&quiz, quizId).Error; err != nil {
+			return err
+		}
+
+		if quiz.Status != QuizStatusPublished {
+			return ErrQuizNotPublished
+		}
+
+		if err := tx.Model(&quiz).Updates(map[string]interface{}{"status": QuizStatusDraft, "published_at": nil}).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditQuizUnpublished, AuditTargetQuiz, quiz.ID, AuditDetails{"title": quiz.Title})
+	})
+
+	return quiz, err
+}
+
+// ReorderQuestions sets the order of the quiz's questions to the one of
+// questionIds, which must list each of them once.
+func ReorderQuestions(adminId uint, quizId uint, questionIds []uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&QuizTable{}, quizId).Error; err != nil {
+			return err
+		}
+
+		var currentIds []uint
+		if err := tx.Model(&QuestionTable{}).Where("quiz_id = ?", quizId).Pluck("id", &currentIds).Error; err != nil {
+			return err
+		}
+
+		if !sameIds(currentIds, questionIds) {
+			return ErrInvalidContentOrder
+		}
+
+		for i, questionId := range questionIds {
+			if err := tx.Model(&QuestionTable{}).Where("id = ?", questionId).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+
+		return RecordAudit(tx, adminId, AuditQuestionsReordered, AuditTargetQuiz, quizId, AuditDetails{"question_ids": questionIds})
+	})
+}
+
+// bumpQuizVersion is called whenever a question or option is added, edited or
+// removed, since that changes what the answers mean and outdates the
+// completions of the previous version. Reordering doesn't.
+func bumpQuizVersion(tx *gorm.DB, quizId uint) error {
+	return tx.Model(&QuizTable{}).Where("id = ?", quizId).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+// validateQuizContent returns a *QuizValidationError when the quiz is not
+// complete enough to be shown to users.
+func validateQuizContent(tx *gorm.DB, quizId uint) error {
+	var questions []QuestionTable
+	err := tx.Preload("Options").Where("quiz_id = ?", quizId).Order("position, id").Find(&questions).Error
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	if len(questions) == 0 {
+		problems = append(problems, "the quiz has no questions")
+	}
+
+	for _, question := range questions {
+		if len(question.Options) < MinOptionsPerQuestion {
+			problems = append(problems, fmt.Sprintf("question %d has %d options, at least %d are required", question.ID, len(question.Options), MinOptionsPerQuestion))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &QuizValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// validatePublishedQuiz keeps a published quiz complete: content changes run
+// it before committing and are rolled back when it fails.
+func validatePublishedQuiz(tx *gorm.DB, quizId uint) error {
+	var quiz QuizTable
+	if err := tx.Select("id", "status").First(&quiz, quizId).Error; err != nil {
+		return err
+	}
+
+	if quiz.Status != QuizStatusPublished {
+		return nil
+	}
+
+	return validateQuizContent(tx, quizId)
+}
+
+func sameIds(current []uint, requested []uint) bool {
+	if len(current) != len(requested) {
+		return false
+	}
+
+	seen := uniqueIds(requested)
+	if len(seen) != len(requested) {
+		return false
+	}
+
+	for _, id := range current {
+		if !seen[id] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReportReasonSpam          = "spam"
+	ReportReasonHarassment    = "harassment"
+	ReportReasonInappropriate = "inappropriate_content"
+	ReportReasonFakeProfile   = "fake_profile"
+	ReportReasonOther         = "other"
+
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+
+	MaxReportedMessages = 20
+
+	DefaultReportPageSize = 50
+	MaxReportPageSize     = 100
+)
+
+var (
+	ErrReportedMessageNotFound = errors.New("reported message not found between the users")
+	ErrReportAlreadyReviewed   = errors.New("report was already reviewed")
+)
+
+// Report is a complaint of ReporterID about ReportedID, waiting for a
+// moderator to review it. ReviewedByID is the admin who resolved or
+// dismissed it.
+type Report struct {
+	ID           uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReporterID   uint            `gorm:"not null;index" json:"reporter_id"`
+	Reporter     User            `gorm:"foreignKey:ReporterID;constraint:OnDelete:CASCADE" json:"-"`
+	ReportedID   uint            `gorm:"not null;index" json:"reported_id"`
+	Reported     User            `gorm:"foreignKey:ReportedID;constraint:OnDelete:CASCADE" json:"-"`
+	Reason       string          `gorm:"size:50;not null" json:"reason"`
+	Details      string          `gorm:"type:text" json:"details"`
+	Status       string          `gorm:"size:20;not null;default:open;index" json:"status"`
+	Messages     []ReportMessage `gorm:"foreignKey:ReportID;constraint:OnDelete:CASCADE" json:"messages"`
+	Resolution   string          `gorm:"type:text" json:"resolution"`
+	ReviewedByID *uint           `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time      `json:"reviewed_at"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type ReportWithUsers struct {
+	Report       `gorm:"embedded"`
+	ReporterName string `json:"reporter_name"`
+	ReportedName string `json:"reported_name"`
+}
+
+// ReportFilter selects one page of reports, newest first. An empty Status
+// matches every report.
+type ReportFilter struct {
+	Status     string
+	ReportedID uint
+	Before     uint
+	Limit      int
+}
+
+// ReportMessage keeps a copy of a reported message, so the evidence survives
+// blocking, which deletes the conversation.
+type ReportMessage struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	ReportID  uint      `gorm:"not null;index" json:"-"`
+	MessageID uint      `gorm:"not null" json:"message_id"`
+	SenderID  uint      `gorm:"not null" json:"sender_id"`
+	Content   string    `gorm:"type:text;not null" json:"content"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+func ValidReportReason(reason string) bool {
+	switch reason {
+	case ReportReasonSpam, ReportReasonHarassment, ReportReasonInappropriate, ReportReasonFakeProfile, ReportReasonOther:
+		return true
+	}
+
+	return false
+}
+
+// CreateReport stores the report with a copy of the given messages, which
+// must all have been exchanged between the reporter and the reported user.
+func CreateReport(reporterId uint, reportedId uint, reason string, details string, messageIds []uint) (Report, error) {
+	report := Report{
+		ReporterID: reporterId,
+		ReportedID: reportedId,
+		Reason:     reason,
+		Details:    details,
+		Status:     ReportStatusOpen,
+		Messages:   make([]ReportMessage, 0),
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if len(messageIds) > 0 {
+			var messages []Message
+			err := tx.Where("id IN ?", messageIds).
+				Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", reporterId, reportedId, reportedId, reporterId).
+				Order("id").
+				Find(&messages).Error
+			if err != nil {
+				return err
+			}
+
+			if len(messages) != len(uniqueIds(messageIds)) {
+				return ErrReportedMessageNotFound
+			}
+
+			for _, message := range messages {
+				report.Messages = append(report.Messages, ReportMessage{
+					MessageID: message.ID,
+					SenderID:  message.SenderID,
+					Content:   message.Content,
+					SentAt:    message.CreatedAt,
+				})
+			}
+		}
+
+		return tx.Omit("Reporter", "Reported").Create(&report).Error
+	})
+
+	return report, err
+}
+
+func uniqueIds(ids []uint) map[uint]bool {
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	return unique
+}
+
+// GetReports records the listing in the audit log, the target id is left at
+// zero since it covers many reports.
+func GetReports(adminId uint, filter ReportFilter) ([]ReportWithUsers, bool, error) {
+	results := make([]ReportWithUsers, 0)
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxReportPageSize {
+		limit = DefaultReportPageSize
+	}
+
+	query := reportsWithUsers(DB)
+
+	if filter.Status != "" {
+		query = query.Where("r.status = ?", filter.Status)
+	}
+
+	if filter.ReportedID > 0 {
+		query = query.Where("r.reported_id = ?", filter.ReportedID)
+	}
+
+	if filter.Before > 0 {
+		query = query.Where("r.id < ?", filter.Before)
+	}
+
+	if err := query.Order("r.id desc").Limit(limit + 1).Scan(&results).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	err := RecordAudit(DB, adminId, AuditReportsListed, AuditTargetReport, 0, AuditDetails{
+		"status":      filter.Status,
+		"reported_id": filter.ReportedID,
+		"results":     len(results),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return results, hasMore, nil
+}
+
+// GetReport records that the admin looked at the report and the messages
+// attached to it.
+func GetReport(adminId uint, reportId uint) (ReportWithUsers, error) {
+	var result ReportWithUsers
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		query := reportsWithUsers(tx).Where("r.id = ?", reportId).Limit(1).Scan(&result)
+		if query.Error != nil {
+			return query.Error
+		}
+
+		if query.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		result.Messages = make([]ReportMessage, 0)
+		if err := tx.Where("report_id = ?", reportId).Order("message_id").Find(&result.Messages).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditReportViewed, AuditTargetReport, reportId, nil)
+	})
+
+	return result, err
+}
+
+// ReviewReport closes an open report as resolved or dismissed.
+func ReviewReport(adminId uint, reportId uint, status string, resolution string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var report Report
+		if err := tx.First(&report, reportId).Error; err != nil {
+			return err
+		}
+
+		if report.Status != ReportStatusOpen {
+			return ErrReportAlreadyReviewed
+		}
+
+		now := time.Now().UTC()
+		err := tx.Model(&report).Updates(map[string]interface{}{
+			"status":         status,
+			"resolution":     resolution,
+			"reviewed_by_id": adminId,
+			"reviewed_at":    now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditReportReviewed, AuditTargetReport, reportId, AuditDetails{
+			"status":      status,
+			"reported_id": report.ReportedID,
+		})
+	})
+}
+
+func reportsWithUsers(db *gorm.DB) *gorm.DB {
+	return db.Table("reports as r").
+		Select("r.*, reporter.name as reporter_name, reported.name as reported_name").
+		Joins("LEFT JOIN users reporter ON reporter.id = r.reporter_id").
+		Joins("LEFT JOIN users reported ON reported.id = r.reported_id")
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"time"
+	"unifriend-api/utils/token"
+
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           uint       `gorm:"not null;index" json:"user_id"`
+	User             User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	RefreshTokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt        time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt        *time.Time `gorm:"default:NULL" json:"revoked_at"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	LastUsedAt       time.Time  `gorm:"autoCreateTime" json:"last_used_at"`
+}
+
+var ErrInvalidSession = errors.New("session is invalid or has expired")
+
+func CreateSession(userId uint) (*Session, string, error) {
+	refreshToken, err := token.GenerateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := &Session{
+		UserID:           userId,
+		RefreshTokenHash: token.HashRefreshToken(refreshToken),
+		ExpiresAt:        time.Now().Add(token.RefreshTokenLifespan()).UTC(),
+	}
+
+	if err := DB.Create(session).Error; err != nil {
+		return nil, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+func RotateSession(refreshToken string) (*Session, string, error) {
+	var session Session
+
+	err := DB.Where("refresh_token_hash = ?", token.HashRefreshToken(refreshToken)).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now().UTC()).
+		First(&session).Error
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, "", ErrInvalidSession
+		}
+		return nil, "", err
+	}
+
+	newRefreshToken, err := token.GenerateRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"RefreshTokenHash": token.HashRefreshToken(newRefreshToken),
+		"ExpiresAt":        now.Add(token.RefreshTokenLifespan()),
+		"LastUsedAt":       now,
+	}
+
+	result := DB.Model(&session).
+		Where("refresh_token_hash = ?", session.RefreshTokenHash).
+		Updates(updates)
+
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, "", ErrInvalidSession
+	}
+
+	return &session, newRefreshToken, nil
+}
+
+func IsSessionActive(sessionId uint, userId uint) bool {
+	var count int64
+
+	err := DB.Model(&Session{}).
+		Where("id = ? AND user_id = ?", sessionId, userId).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now().UTC()).
+		Count(&count).Error
+
+	if err != nil {
+		return false
+	}
+
+	return count > 0
+}
+
+func RevokeSession(sessionId uint, userId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, "id = ? AND user_id = ?", sessionId, userId)
+	})
+}
+
+func RevokeUserSessions(userId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, "user_id = ?", userId)
+	})
+}
+
+func RevokeOtherUserSessions(userId uint, currentSessionId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, "user_id = ? AND id <> ?", userId, currentSessionId)
+	})
+}
+
+// revokeSessions revokes the active sessions matching the condition and drops
+// the push subscriptions registered from them, so a signed out browser stops
+// receiving message content.
+func revokeSessions(tx *gorm.DB, condition string, args ...interface{}) error {
+	sessionIds := tx.Model(&Session{}).Select("id").Where("revoked_at IS NULL").Where(condition, args...)
+
+	if err := tx.Where("session_id IN (?)", sessionIds).Delete(&DeviceToken{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&Session{}).
+		Where("revoked_at IS NULL").
+		Where(condition, args...).
+		Update("revoked_at", time.Now().UTC()).Error
+}
@@ -36,6 +36,17 @@ func SetupTestDB() {
 		&Connection{},
 		&ConnectionRequest{},
 		&Message{},
+		&Session{},
+		&PasswordReset{},
+		&DataExport{},
+		&DeviceToken{},
+		&Notification{},
+		&Block{},
+		&Report{},
+		&ReportMessage{},
+		&AuditLog{},
+		&QuizCompletion{},
+		&EmailChange{},
 	)
 }
 
@@ -47,6 +58,15 @@ func TearDownTestDB() {
 	DB.Rollback()
 }
 
+func CloseDataBase() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
 func ConnectDataBase() {
 	newLogger := logger.New(
 		log.New(os.Stdout, "\r\n", log.LstdFlags),
@@ -93,5 +113,24 @@ func ConnectDataBase() {
 		&Connection{},
 		&ConnectionRequest{},
 		&Message{},
+		&Session{},
+		&PasswordReset{},
+		&DataExport{},
+		&DeviceToken{},
+		&Notification{},
+		&Block{},
+		&Report{},
+		&ReportMessage{},
+		&AuditLog{},
+		&QuizCompletion{},
+		&EmailChange{},
 	)
+
+	// idx_messages_receiver_unread starts with receiver_id, which makes the
+	// single column index created by older versions redundant.
+	if DB.Migrator().HasIndex(&Message{}, "idx_messages_receiver_id") {
+		if err := DB.Migrator().DropIndex(&Message{}, "idx_messages_receiver_id"); err != nil {
+			log.Printf("Cannot drop index idx_messages_receiver_id: %v", err)
+		}
+	}
 }
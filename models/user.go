@@ -2,12 +2,26 @@ package models
 
 import (
 	"errors"
+	"log"
+	"os"
+	"strconv"
 	"strings"
 	"time"
-	"unifriend-api/utils/token"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	UserStatusDeleted   = 0
+	UserStatusActive    = 1
+	UserStatusSuspended = 2
+)
+
+var (
+	ErrUserNotActive    = errors.New("user is not active")
+	ErrUserNotSuspended = errors.New("user is not suspended")
 )
 
 type User struct {
@@ -18,12 +32,18 @@ type User struct {
 	ProfilePictureURL string `gorm:"size:255"`
 	IsAdmin           bool   `gorm:"default:false"`
 	PhoneNumber       string `gorm:"size:20;not null"`
+	Bio               string `gorm:"size:500"`
 	MajorID           uint
-	Major             Major	`gorm:"foreignKey:MajorID"`
-	Status			  int  `gorm:"default:1"`
-	Images            []UsersImages `gorm:"foreignKey:UserID"`
+	Major             Major          `gorm:"foreignKey:MajorID"`
+	Status            int            `gorm:"default:1"`
+	Images            []UsersImages  `gorm:"foreignKey:UserID"`
 	UserResponses     []UserResponse `gorm:"foreignKey:UserID"`
-	DeletedAt        time.Time `gorm:"default:NULL"`
+	DeletedAt         *time.Time     `gorm:"default:NULL"`
+	LastSeenAt        *time.Time     `gorm:"precision:3;default:NULL"`
+}
+
+func UpdateLastSeen(userId uint, lastSeen time.Time) error {
+	return DB.Model(&User{}).Where("id = ?", userId).UpdateColumn("last_seen_at", lastSeen).Error
 }
 
 func GetUserByID(uid uint) (User, error) {
@@ -39,6 +59,59 @@ func GetUserByID(uid uint) (User, error) {
 	return u, nil
 }
 
+// GetVisibleUserProfile returns the profile of an active user unless one of
+// the two users blocked the other.
+func GetVisibleUserProfile(viewerId uint, uid uint) (User, error) {
+	var u User
+
+	err := DB.Preload("Major").Preload("Images").
+		Where("status = 1 AND deleted_at IS NULL").
+		Where(notBlocked("users.id"), viewerId, viewerId).
+		First(&u, uid).Error
+
+	if err != nil {
+		return u, err
+	}
+
+	u.PrepareGive()
+
+	return u, nil
+}
+
+func GetActiveUserProfile(uid uint) (User, error) {
+	var u User
+
+	err := DB.Preload("Major").Preload("Images").
+		Where("status = 1 AND deleted_at IS NULL").
+		First(&u, uid).Error
+
+	if err != nil {
+		return u, err
+	}
+
+	u.PrepareGive()
+
+	return u, nil
+}
+
+func (u *User) UpdateProfile(updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return DB.Model(u).Omit(clause.Associations).Updates(updates).Error
+}
+
+func GetActiveUserByEmail(email string) (User, error) {
+	var u User
+
+	err := DB.Where("email = ?", strings.TrimSpace(email)).
+		Where("status = 1 AND deleted_at IS NULL").
+		Take(&u).Error
+
+	return u, err
+}
+
 func UsernameAlreadyUsed(email string) bool {
 	var count int64
 	DB.Model(&User{}).Where("email = ?", email).Count(&count)
@@ -51,6 +124,12 @@ func PhoneNumberAlreadyUsed(phoneNumber string) bool {
 	return count > 0
 }
 
+func PhoneNumberUsedByOtherUser(phoneNumber string, userId uint) bool {
+	var count int64
+	DB.Model(&User{}).Where("phone_number = ? AND id <> ?", phoneNumber, userId).Count(&count)
+	return count > 0
+}
+
 func (u *User) PrepareGive() {
 	u.Password = ""
 }
@@ -63,23 +142,66 @@ func (u *User) SaveUser() (*User, error) {
 	return u, nil
 }
 
-func (u *User) BeforeSave(DB *gorm.DB) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+func (u *User) UpdatePassword(newPassword string) error {
+	if err := u.SetPassword(newPassword); err != nil {
+		return err
+	}
+
+	return DB.Model(u).UpdateColumn("password", u.Password).Error
+}
+
+// SetPassword hashes a plain text password with the configured bcrypt cost.
+// It is the only way a password should change after the user is created, so
+// later saves of a loaded user never hash the stored hash again.
+func (u *User) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost())
 
 	if err != nil {
 		return err
 	}
+
 	u.Password = string(hashedPassword)
+	return nil
+}
+
+func (u *User) BeforeCreate(DB *gorm.DB) error {
+	if isPasswordHash(u.Password) {
+		return nil
+	}
+
+	return u.SetPassword(u.Password)
+}
+
+func (u *User) BeforeSave(DB *gorm.DB) error {
 	u.Email = strings.TrimSpace(u.Email)
 
 	return nil
 }
 
+func PasswordHashCost() int {
+	cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+
+	return cost
+}
+
+func isPasswordHash(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
+
+func needsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err == nil && cost != PasswordHashCost()
+}
+
 func VerifyPassword(password, hashedPassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func LoginCheck(email string, password string) (string, User) {
+func LoginCheck(email string, password string) (User, error) {
 	var err error
 
 	u := User{}
@@ -87,36 +209,338 @@ func LoginCheck(email string, password string) (string, User) {
 	err = DB.Model(&User{}).Where("email = ?", email).Where("status = 1 AND deleted_at IS NULL").Take(&u).Error
 
 	if err != nil {
-		return "", User{}
+		return User{}, err
 	}
 
 	err = VerifyPassword(password, u.Password)
 
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-		return "", User{}
+	if err != nil {
+		return User{}, err
+	}
+
+	if needsRehash(u.Password) {
+		if err := u.UpdatePassword(password); err != nil {
+			log.Printf("failed to rehash password for user %d: %v", u.ID, err)
+		}
 	}
 
-	token, err := token.GenerateToken(u.ID)
+	return u, nil
+
+}
+
+func (u *User) DeleteUser() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"Status":            0,
+			"DeletedAt":         time.Now().UTC(),
+			"ProfilePictureURL": "",
+			"Bio":               "",
+			"LastSeenAt":        nil,
+		}
+
+		if err := tx.Model(&u).Omit(clause.Associations).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Message{}).Where("sender_id = ?", u.ID).Update("content", "").Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", u.ID).Delete(&UsersImages{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", u.ID).Delete(&UserResponse{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", u.ID).Delete(&QuizCompletion{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("(requesting_user_id = ? OR requested_user_id = ?) AND status <> ?", u.ID, u.ID, StatusAccepted).
+			Delete(&ConnectionRequest{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", u.ID).Delete(&DeviceToken{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ? OR actor_id = ?", u.ID, u.ID).Delete(&Notification{}).Error; err != nil {
+			return err
+		}
+
+		return revokeSessions(tx, "user_id = ?", u.ID)
+	})
+}
+
+// PurgeDeletedUsers hard deletes every account that was soft deleted before
+// the grace period, together with the rows that still reference it.
+func PurgeDeletedUsers(gracePeriod time.Duration) (int64, error) {
+	var users []User
+
+	err := DB.Where("status = 0 AND deleted_at IS NOT NULL AND deleted_at < ?", time.Now().UTC().Add(-gracePeriod)).
+		Find(&users).Error
 
 	if err != nil {
-		return "", User{}
+		return 0, err
 	}
 
-	return token, u
+	var purged int64
+	for _, user := range users {
+		if err := purgeUser(user); err != nil {
+			return purged, err
+		}
+		purged++
+	}
 
+	return purged, nil
 }
 
-func (u *User) DeleteUser() error {
-    return DB.Transaction(func(tx *gorm.DB) error {
-        updates := map[string]interface{}{
-            "Status":    0,
-            "DeletedAt": time.Now().Format("2006-01-02 15:04:05"),
+func purgeUser(user User) error {
+	exportFiles, err := getUserDataExportFiles(user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		connectionIds := tx.Model(&Connection{}).Select("id").Where("user_a = ? OR user_b = ?", user.ID, user.ID)
+
+		if err := tx.Where("sender_id = ? OR receiver_id = ? OR connection_id IN (?)", user.ID, user.ID, connectionIds).
+			Delete(&Message{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_a = ? OR user_b = ?", user.ID, user.ID).Delete(&Connection{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("requesting_user_id = ? OR requested_user_id = ?", user.ID, user.ID).
+			Delete(&ConnectionRequest{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&UserResponse{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&QuizCompletion{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&UsersImages{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&Session{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&DeviceToken{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ? OR actor_id = ?", user.ID, user.ID).Delete(&Notification{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("blocker_id = ? OR blocked_id = ?", user.ID, user.ID).Delete(&Block{}).Error; err != nil {
+			return err
+		}
+
+		reportIds := tx.Model(&Report{}).Select("id").Where("reporter_id = ? OR reported_id = ?", user.ID, user.ID)
+
+		if err := tx.Where("report_id IN (?)", reportIds).Delete(&ReportMessage{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("reporter_id = ? OR reported_id = ?", user.ID, user.ID).Delete(&Report{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&DataExport{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("email = ?", user.Email).Delete(&PasswordReset{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("email = ?", user.Email).Delete(&EmailsVerification{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&EmailChange{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&User{}, user.ID).Error
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, filePath := range exportFiles {
+		if err := removeDataExportFile(filePath); err != nil {
+			log.Printf("error removing data export file of user %d: %v", user.ID, err)
+		}
+	}
+
+	return nil
+}
+
+const (
+	DefaultAdminUserPageSize = 50
+	MaxAdminUserPageSize     = 100
+)
+
+// AdminUser is what the admin console sees of an account, without the
+// password hash.
+type AdminUser struct {
+	ID                uint       `json:"id"`
+	Email             string     `json:"email"`
+	Name              string     `json:"name"`
+	PhoneNumber       string     `json:"phone_number"`
+	ProfilePictureURL string     `json:"profile_picture_url"`
+	Bio               string     `json:"bio"`
+	MajorID           uint       `json:"major_id"`
+	IsAdmin           bool       `json:"is_admin"`
+	Status            int        `json:"status"`
+	DeletedAt         *time.Time `json:"deleted_at"`
+	LastSeenAt        *time.Time `json:"last_seen_at"`
+}
+
+// AdminUserFilter selects one page of users ordered by id. Query matches the
+// name or the email, Status is ignored when nil.
+type AdminUserFilter struct {
+	Query  string
+	Status *int
+	Before uint
+	Limit  int
+}
+
+// SearchUsers records the search in the audit log, the target id is left at
+// zero since it covers many users.
+func SearchUsers(adminId uint, filter AdminUserFilter) ([]AdminUser, bool, error) {
+	results := make([]AdminUser, 0)
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxAdminUserPageSize {
+		limit = DefaultAdminUserPageSize
+	}
+
+	query := DB.Model(&User{})
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+
+	if filter.Before > 0 {
+		query = query.Where("id < ?", filter.Before)
+	}
+
+	if err := query.Order("id desc").Limit(limit + 1).Scan(&results).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	err := RecordAudit(DB, adminId, AuditUsersSearched, AuditTargetUser, 0, AuditDetails{
+		"query":   filter.Query,
+		"status":  filter.Status,
+		"results": len(results),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return results, hasMore, nil
+}
+
+func GetAdminUser(userId uint) (AdminUser, error) {
+	var result AdminUser
+	err := DB.Model(&User{}).Where("id = ?", userId).Take(&result).Error
+	return result, err
+}
+
+// GetUserForAdmin records that the admin looked at the user's account.
+func GetUserForAdmin(adminId uint, userId uint) (AdminUser, error) {
+	var result AdminUser
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", userId).Take(&result).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditUserViewed, AuditTargetUser, userId, nil)
+	})
+
+	return result, err
+}
+
+// SuspendUser locks an active account out: it can not log in anymore and
+// every session it has is revoked.
+func SuspendUser(adminId uint, userId uint, reason string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("id = ? AND status = ? AND deleted_at IS NULL", userId, UserStatusActive).
+			Update("status", UserStatusSuspended)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrUserNotActive
+		}
+
+		if err := revokeSessions(tx, "user_id = ?", userId); err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditUserSuspended, AuditTargetUser, userId, AuditDetails{"reason": reason})
+	})
+}
+
+func ReactivateUser(adminId uint, userId uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("id = ? AND status = ?", userId, UserStatusSuspended).
+			Update("status", UserStatusActive)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrUserNotSuspended
 		}
 
-        if err := tx.Model(&u).Updates(updates).Error; err != nil {
-            return err
-        }
+		return RecordAudit(tx, adminId, AuditUserReactivated, AuditTargetUser, userId, nil)
+	})
+}
+
+// GetUserImagesForAdmin records that the admin looked at the user's images.
+func GetUserImagesForAdmin(adminId uint, userId uint) ([]UsersImages, error) {
+	images := make([]UsersImages, 0)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Order("id").Find(&images).Error; err != nil {
+			return err
+		}
+
+		return RecordAudit(tx, adminId, AuditUserImagesViewed, AuditTargetUser, userId, AuditDetails{"images": len(images)})
+	})
 
-        return nil
-    })
-}
\ No newline at end of file
+	return images, err
+}
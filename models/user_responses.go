@@ -39,27 +39,30 @@ func SaveUserResponses(userResponses []UserResponse) error {
 	return nil
 }
 
-func GetUserResponsesByUserID(userId uint) ([]UserResponse, error) {
+// GetUserResponsesByUserID returns the user's answers, limited to the given
+// quizzes when any are passed.
+func GetUserResponsesByUserID(userId uint, quizIds ...uint) ([]UserResponse, error) {
 	var userResponses []UserResponse
 
-	if err := DB.Where("user_id = ?", userId).Find(&userResponses).Error; err != nil {
+	query := DB.Where("user_id = ?", userId)
+	if len(quizIds) > 0 {
+		query = query.Where("question_id IN (?)", DB.Model(&QuestionTable{}).Select("id").Where("quiz_id IN ?", quizIds))
+	}
+
+	if err := query.Find(&userResponses).Error; err != nil {
 		return userResponses, err
 	}
 
 	return userResponses, nil
 }
 
-func HasUserAlreadyTakenQuiz(userID uint) (bool, error) {
-	var count int64
-	err := DB.Model(&UserResponse{}).
-		Where("user_responses.user_id = ?", userID).
-		Count(&count).Error
-
+func HasUserAlreadyTakenQuiz(userID uint, quizID uint) (bool, error) {
+	taken, err := GetTakenQuizIDs(userID)
 	if err != nil {
 		return false, err
 	}
 
-	return count > 0, nil
+	return taken[quizID], nil
 }
 
 func GetMatchingResponsesFromOtherUsers(currentUserID uint, currentUserAnswers []UserResponse) ([]MatchingUserResponse, error) {
@@ -103,6 +106,7 @@ func GetMatchingResponsesFromOtherUsers(currentUserID uint, currentUserAnswers [
         Preload("User").
         Where("users.deleted_at IS NULL AND users.status = 1").
         Where("user_responses.user_id != ?", currentUserID).
+        Where(notBlocked("user_responses.user_id"), currentUserID, currentUserID).
         Where(queryConditions.String(), queryArgs...).
         Find(&matchingResponses).Error
 
@@ -11,7 +11,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRoutes(r *gin.Engine, hub *services.Hub) {
+func SetupRoutes(r *gin.Engine, hub *services.Hub, dataExporter *services.DataExporter) {
 	public := r.Group("/api")
 	private := r.Group("/api")
 	register := r.Group("/api")
@@ -21,6 +21,10 @@ func SetupRoutes(r *gin.Engine, hub *services.Hub) {
 	
 	users := private.Group("/users")
 	connections := private.Group("/connections")
+	notifications := private.Group("/notifications")
+	admin := private.Group("/admin")
+
+	admin.Use(middleware.AdminMiddleware())
 
 	if gin.Mode() != gin.TestMode {
 		s3Client, err := services.NewS3Client()
@@ -59,27 +63,102 @@ func SetupRoutes(r *gin.Engine, hub *services.Hub) {
 			handlers.DeleteUserImage(c, s3Client)
 		})
 
+		users.DELETE("/me", func(c *gin.Context) {
+			handlers.DeleteUserAccount(c, s3Client)
+		})
+
+		users.POST("/me/export", func(c *gin.Context) {
+			handlers.RequestDataExport(c, dataExporter)
+		})
+
 		public.GET("/verify/email/:email", func(c *gin.Context) {
 			handlers.VerifyEmail(c, sesClient)
 		})
+
+		public.POST("/password/forgot", func(c *gin.Context) {
+			handlers.ForgotPassword(c, sesClient)
+		})
+
+		users.POST("/me/email", func(c *gin.Context) {
+			handlers.ChangeEmail(c, sesClient)
+		})
 	}
+	users.GET("/me", handlers.GetMyProfile)
+	users.PATCH("/me", handlers.UpdateMyProfile)
+	users.GET("/:id", handlers.GetUserProfile)
+	users.PUT("/me/password", handlers.ChangePassword)
+	users.GET("/me/export/:export_id", handlers.GetDataExport)
+	users.GET("/me/export/:export_id/download", handlers.DownloadDataExport)
+	users.POST("/me/email/confirm", handlers.ConfirmEmailChange)
+	users.POST("/me/devices", handlers.RegisterDeviceToken)
+	users.GET("/me/devices", handlers.GetDeviceTokens)
+	users.DELETE("/me/devices/:device_id", handlers.DeleteDeviceToken)
+	users.GET("/me/blocks", handlers.GetBlockedUsers)
+	users.POST("/:id/block", handlers.BlockUser)
+	users.DELETE("/:id/block", handlers.UnblockUser)
+	users.POST("/:id/report", handlers.ReportUser)
 	connections.POST("/request/user/:user_id", handlers.CreateConnectionRequest)
 	connections.GET("/requests", handlers.GetConnectionRequests)
 	connections.PUT("/requests/:request_id/accept", handlers.AcceptConnectionRequest)
 	connections.PUT("/requests/:request_id/reject", handlers.RejectConnectionRequest)
 	connections.DELETE("/:connection_id", handlers.DeleteConnection)
 	connections.GET("", handlers.GetConnections)
+	connections.GET("/unread", handlers.GetUnreadCount)
 	connections.GET("/messages/:connection_id", handlers.GetMessages)
+	connections.POST("/messages/:connection_id", handlers.SendMessage)
+	connections.POST("/messages/:connection_id/read", handlers.MarkMessagesRead)
+	notifications.GET("", handlers.GetNotifications)
+	notifications.POST("/read", handlers.MarkAllNotificationsRead)
+	notifications.POST("/:notification_id/read", handlers.MarkNotificationRead)
+	admin.GET("/users", handlers.SearchUsers)
+	admin.GET("/users/:id", handlers.GetAdminUser)
+	admin.GET("/users/:id/images", handlers.GetAdminUserImages)
+	admin.POST("/users/:id/suspend", handlers.SuspendUser)
+	admin.POST("/users/:id/reactivate", handlers.ReactivateUser)
+	admin.GET("/reports", handlers.GetReports)
+	admin.GET("/reports/:report_id", handlers.GetReport)
+	admin.PUT("/reports/:report_id", handlers.ReviewReport)
+	admin.GET("/audit-logs", handlers.GetAuditLogs)
+	admin.POST("/majors", handlers.CreateMajor)
+	admin.PUT("/majors/:major_id", handlers.UpdateMajor)
+	admin.DELETE("/majors/:major_id", handlers.DeleteMajor)
+	admin.GET("/email-domains", handlers.GetEmailDomains)
+	admin.POST("/email-domains", handlers.CreateEmailDomain)
+	admin.PUT("/email-domains/:domain_id", handlers.UpdateEmailDomain)
+	admin.DELETE("/email-domains/:domain_id", handlers.DeleteEmailDomain)
+	admin.GET("/quizzes", handlers.GetAdminQuizzes)
+	admin.GET("/quizzes/:quiz_id", handlers.GetAdminQuiz)
+	admin.POST("/quizzes", handlers.CreateQuiz)
+	admin.PUT("/quizzes/:quiz_id", handlers.UpdateQuiz)
+	admin.DELETE("/quizzes/:quiz_id", handlers.DeleteQuiz)
+	admin.POST("/quizzes/:quiz_id/publish", handlers.PublishQuiz)
+	admin.POST("/quizzes/:quiz_id/unpublish", handlers.UnpublishQuiz)
+	admin.POST("/quizzes/:quiz_id/questions", handlers.CreateQuestion)
+	admin.PUT("/quizzes/:quiz_id/questions/order", handlers.ReorderQuestions)
+	admin.PUT("/questions/:question_id", handlers.UpdateQuestion)
+	admin.DELETE("/questions/:question_id", handlers.DeleteQuestion)
+	admin.POST("/questions/:question_id/options", handlers.CreateOption)
+	admin.PUT("/questions/:question_id/options/order", handlers.ReorderOptions)
+	admin.PUT("/options/:option_id", handlers.UpdateOption)
+	admin.DELETE("/options/:option_id", handlers.DeleteOption)
 	public.GET("/verify/code/:email", handlers.GetVerificationCodeExpiration)
 	private.GET("/questions", handlers.GetQuestions)
+	private.GET("/quizzes", handlers.GetQuizzes)
+	private.GET("/quizzes/:quiz_id/questions", handlers.GetQuizQuestions)
+	private.GET("/quizzes/:quiz_id/answers", handlers.GetMyQuizAnswers)
+	private.PUT("/quizzes/:quiz_id/answers", handlers.UpdateQuizAnswers)
 	private.GET("/get-results/user/:user_id", handlers.GetResults)
 	public.GET("/health", Ping)
 	public.GET("/majors", handlers.GetMajors)
+	public.GET("/push/public-key", handlers.GetPushPublicKey)
 	public.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	public.POST("/verify/email", handlers.VerifyEmailCode)
 	register.POST("/register", handlers.Register)
 	private.POST("/answer/save", handlers.SaveAnswers)
 	private.GET("/logout", handlers.Logout)
+	private.POST("/logout/all", handlers.LogoutEverywhere)
+	public.POST("/token/refresh", handlers.RefreshToken)
+	public.POST("/password/reset", handlers.ResetPassword)
 	public.POST("/login", handlers.Login)
 }
 
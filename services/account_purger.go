@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+	"unifriend-api/models"
+)
+
+const (
+	defaultAccountDeletionGraceDays = 30
+	defaultAccountPurgeIntervalMins = 60
+)
+
+type AccountPurger struct {
+	interval    time.Duration
+	gracePeriod time.Duration
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+func NewAccountPurger() *AccountPurger {
+	graceDays, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD_DAYS"))
+	if err != nil || graceDays < 0 {
+		graceDays = defaultAccountDeletionGraceDays
+	}
+
+	intervalMinutes, err := strconv.Atoi(os.Getenv("ACCOUNT_PURGE_INTERVAL_MINUTES"))
+	if err != nil || intervalMinutes <= 0 {
+		intervalMinutes = defaultAccountPurgeIntervalMins
+	}
+
+	return &AccountPurger{
+		interval:    time.Duration(intervalMinutes) * time.Minute,
+		gracePeriod: time.Duration(graceDays) * 24 * time.Hour,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+func (p *AccountPurger) Run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge()
+
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *AccountPurger) Stop() {
+	close(p.stop)
+}
+
+// Shutdown stops the ticker and waits for a purge that is already running to
+// finish, so the database isn't closed under it, or for ctx to expire.
+func (p *AccountPurger) Shutdown(ctx context.Context) error {
+	p.Stop()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *AccountPurger) purge() {
+	purged, err := models.PurgeDeletedUsers(p.gracePeriod)
+	if err != nil {
+		log.Printf("error purging deleted accounts: %v", err)
+	}
+
+	if purged > 0 {
+		log.Printf("purged %d deleted accounts", purged)
+	}
+
+	failed, err := models.FailStaleDataExports()
+	if err != nil {
+		log.Printf("error failing stale data exports: %v", err)
+	}
+
+	if failed > 0 {
+		log.Printf("marked %d stale data exports as failed", failed)
+	}
+
+	removed, err := models.RemoveExpiredDataExportFiles()
+	if err != nil {
+		log.Printf("error removing expired data export files: %v", err)
+	}
+
+	if removed > 0 {
+		log.Printf("removed %d expired data export files", removed)
+	}
+}
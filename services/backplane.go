@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultBackplaneChannel = "unifriend:chat"
+	memoryBackplaneBuffer   = 1024
+
+	// memoryBackplanePublishWait bounds how long Publish waits for a
+	// subscriber that is behind, e.g. a hub that already stopped.
+	memoryBackplanePublishWait = time.Second
+)
+
+// Delivery is a frame addressed to every device of a user, wherever their
+// sockets are connected. SkipClient is the id of a socket that must not get
+// the frame, usually the one that caused it. SenderID is the user whose action
+// produced the frame, it is dropped when either user blocked the other.
+//
+// A delivery with Blocked or Unblocked set carries no frame, it tells the
+// sockets of UserID to stop or resume exchanging events with that user.
+// Disconnect closes every socket of UserID.
+type Delivery struct {
+	UserID     uint            `json:"user_id"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	SkipClient string          `json:"skip_client,omitempty"`
+	SenderID   uint            `json:"sender_id,omitempty"`
+	Blocked    uint            `json:"blocked,omitempty"`
+	Unblocked  uint            `json:"unblocked,omitempty"`
+	Disconnect bool            `json:"disconnect,omitempty"`
+}
+
+// Backplane carries deliveries between every hub of the deployment, so a
+// replica can reach sockets held by another one. A hub publishes everything
+// it routes and delivers only what it receives from its subscription.
+type Backplane interface {
+	Publish(delivery *Delivery) error
+	Subscribe() (<-chan *Delivery, error)
+	Close() error
+}
+
+// NewBackplane uses Redis pub/sub when REDIS_URL is set and falls back to an
+// in-memory backplane, which is enough for a single replica.
+func NewBackplane() (Backplane, error) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return NewMemoryBackplane(), nil
+	}
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := os.Getenv("CHAT_BACKPLANE_CHANNEL")
+	if channel == "" {
+		channel = defaultBackplaneChannel
+	}
+
+	return NewRedisBackplane(redis.NewClient(options), channel), nil
+}
+
+type MemoryBackplane struct {
+	mu          sync.RWMutex
+	subscribers []chan *Delivery
+	closed      bool
+
+	// waiting counts the publishers waiting on a full subscriber outside the
+	// lock, Close lets them give up through done before closing the channels.
+	waiting sync.WaitGroup
+	done    chan struct{}
+}
+
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{done: make(chan struct{})}
+}
+
+// Publish waits for a subscriber whose buffer is full instead of dropping the
+// delivery, the hub never publishes from its own loop so it can't wait on
+// itself. Only a subscriber that stays full for memoryBackplanePublishWait
+// misses it. The wait happens outside the lock, so a slow subscriber doesn't
+// keep others from subscribing or closing the backplane.
+func (b *MemoryBackplane) Publish(delivery *Delivery) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return nil
+	}
+
+	var full []chan *Delivery
+	for _, subscriber := range b.subscribers {
+		select {
+		case subscriber <- delivery:
+		default:
+			full = append(full, subscriber)
+		}
+	}
+
+	if len(full) == 0 {
+		b.mu.RUnlock()
+		return nil
+	}
+
+	b.waiting.Add(1)
+	b.mu.RUnlock()
+	defer b.waiting.Done()
+
+	for _, subscriber := range full {
+		timer := time.NewTimer(memoryBackplanePublishWait)
+		select {
+		case subscriber <- delivery:
+		case <-timer.C:
+			log.Printf("backplane subscriber is full, dropping delivery to user %d", delivery.UserID)
+		case <-b.done:
+			timer.Stop()
+			return nil
+		}
+		timer.Stop()
+	}
+
+	return nil
+}
+
+func (b *MemoryBackplane) Subscribe() (<-chan *Delivery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscriber := make(chan *Delivery, memoryBackplaneBuffer)
+	if b.closed {
+		close(subscriber)
+		return subscriber, nil
+	}
+
+	b.subscribers = append(b.subscribers, subscriber)
+	return subscriber, nil
+}
+
+func (b *MemoryBackplane) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.closed = true
+	close(b.done)
+	subscribers := b.subscribers
+	b.subscribers = nil
+	b.mu.Unlock()
+
+	b.waiting.Wait()
+	for _, subscriber := range subscribers {
+		close(subscriber)
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"log"
 	"net/http"
 	"os"
@@ -12,96 +15,242 @@ import (
 )
 
 const (
-    writeWait      = 10 * time.Second
-    pongWait       = 60 * time.Second
-    pingPeriod     = (pongWait * 9) / 10
-    maxMessageSize = 512
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 16 * 1024
 )
 
 var upgrader = websocket.Upgrader{
-    ReadBufferSize:  1024,
-    WriteBufferSize: 1024,
-    CheckOrigin: func(r *http.Request) bool {
-        origin := r.Header.Get("Origin")
-        allowedOrigin := os.Getenv("CLIENT_DOMAIN")
-        return origin == allowedOrigin
-    },
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		allowedOrigin := os.Getenv("CLIENT_DOMAIN")
+		return origin == allowedOrigin
+	},
 }
 
 type Client struct {
-    hub *Hub
+	hub *Hub
 
-    conn *websocket.Conn
-    send chan *models.Message
-    userID uint
+	// id identifies the socket across every replica's hub.
+	id string
+
+	conn   *websocket.Conn
+	send   chan []byte
+	userID uint
+
+	// blocked are the users this client's user blocked or was blocked by,
+	// events they cause are not delivered to it. Only the hub goroutine
+	// touches it once the client is registered.
+	blocked map[uint]bool
 }
 
 func (c *Client) readPump() {
-    defer func() {
-        c.hub.unregister <- c
-        c.conn.Close()
-    }()
-    c.conn.SetReadLimit(maxMessageSize)
-    c.conn.SetReadDeadline(time.Now().Add(pongWait))
-    c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
-    for {
-        var msg models.Message
-        err := c.conn.ReadJSON(&msg)
-        if err != nil {
-            if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-                log.Printf("error: %v", err)
-            }
-            break
-        }
-        models.DB.Create(&msg)
-        c.hub.broadcast <- &msg
-    }
+	defer func() {
+		c.hub.unregisterClient(c)
+		c.conn.Close()
+		c.hub.disconnected(c)
+		c.hub.pumps.Done()
+	}()
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("error: %v", err)
+			}
+			break
+		}
+
+		var envelope Envelope
+		if err := json.Unmarshal(data, &envelope); err != nil || envelope.Type == "" {
+			c.replyError("", ErrorInvalidFrame, "frame is not a valid envelope")
+			continue
+		}
+
+		c.handleEnvelope(envelope)
+	}
+}
+
+func (c *Client) handleEnvelope(envelope Envelope) {
+	switch envelope.Type {
+	case EventMessageNew:
+		c.sendMessage(envelope)
+	case EventTypingStart, EventTypingStop:
+		c.sendTyping(envelope)
+	case EventMessageRead:
+		c.sendRead(envelope)
+	default:
+		c.replyError(envelope.ID, ErrorUnknownType, "unknown frame type")
+	}
+}
+
+func (c *Client) sendMessage(envelope Envelope) {
+	var payload NewMessagePayload
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+		c.replyError(envelope.ID, ErrorInvalidFrame, "invalid message payload")
+		return
+	}
+
+	content, err := models.ValidateMessageContent(payload.Content)
+	if err != nil {
+		c.replyError(envelope.ID, ErrorInvalidContent, err.Error())
+		return
+	}
+
+	connection, err := models.GetActiveUserConnection(payload.ConnectionID, c.userID)
+	if err != nil {
+		c.replyError(envelope.ID, ErrorConnectionNotFound, "connection not found")
+		return
+	}
+
+	message, err := models.CreateMessage(connection, c.userID, content)
+	if err != nil {
+		log.Printf("error saving message: %v", err)
+		c.replyError(envelope.ID, ErrorInternal, "message could not be sent")
+		return
+	}
+
+	c.hub.replyTo(c, newEnvelope(EventMessageAck, envelope.ID, message))
+	c.hub.broadcastFrom(&message, c.id)
+}
+
+func (c *Client) sendTyping(envelope Envelope) {
+	var payload TypingPayload
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+		c.replyError(envelope.ID, ErrorInvalidFrame, "invalid typing payload")
+		return
+	}
+
+	connection, err := models.GetActiveUserConnection(payload.ConnectionID, c.userID)
+	if err != nil {
+		c.replyError(envelope.ID, ErrorConnectionNotFound, "connection not found")
+		return
+	}
+
+	payload.UserID = c.userID
+	c.hub.SendFromUser(connection.OtherUserID(c.userID), c.userID, newEnvelope(envelope.Type, "", payload))
+}
+
+func (c *Client) sendRead(envelope Envelope) {
+	var payload ReadPayload
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil || payload.MessageID == 0 {
+		c.replyError(envelope.ID, ErrorInvalidFrame, "invalid read payload")
+		return
+	}
+
+	connection, err := models.GetActiveUserConnection(payload.ConnectionID, c.userID)
+	if err != nil {
+		c.replyError(envelope.ID, ErrorConnectionNotFound, "connection not found")
+		return
+	}
+
+	if !models.MessageInConnection(payload.MessageID, connection.ID) {
+		c.replyError(envelope.ID, ErrorMessageNotFound, "message not found")
+		return
+	}
+
+	updated, readAt, err := models.MarkMessagesRead(connection.ID, c.userID, payload.MessageID)
+	if err != nil {
+		log.Printf("error marking messages read: %v", err)
+		c.replyError(envelope.ID, ErrorInternal, "messages could not be marked as read")
+		return
+	}
+
+	if updated > 0 {
+		c.hub.NotifyRead(connection, c.userID, payload.MessageID, readAt)
+	}
+}
+
+func (c *Client) replyError(id string, code string, message string) {
+	c.hub.replyTo(c, newErrorEnvelope(id, code, message))
 }
 
 func (c *Client) writePump() {
-    ticker := time.NewTicker(pingPeriod)
-    defer func() {
-        ticker.Stop()
-        c.conn.Close()
-    }()
-    for {
-        select {
-        case message, ok := <-c.send:
-            c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-            if !ok {
-                c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-                return
-            }
-
-            err := c.conn.WriteJSON(message)
-            if err != nil {
-                return
-            }
-
-        case <-ticker.C:
-            c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-            if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-                return
-            }
-        }
-    }
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.hub.pumps.Done()
+	}()
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+
+			err := c.conn.WriteMessage(websocket.TextMessage, message)
+			if err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+			if err := c.hub.presence.Refresh(c.userID, c.id); err != nil {
+				log.Printf("error refreshing presence of user %d: %v", c.userID, err)
+			}
+		}
+	}
+}
+
+func newClientID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func ServeWs(hub *Hub, c *gin.Context) {
-    userID, exists := c.Get("user_id")
-    if !exists {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-        return
-    }
-
-    conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-    if err != nil {
-        log.Println(err)
-        return
-    }
-    client := &Client{hub: hub, conn: conn, send: make(chan *models.Message, 256), userID: userID.(uint)}
-    client.hub.register <- client
-
-    go client.writePump()
-    go client.readPump()
-}
\ No newline at end of file
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	peers, err := models.GetConnectedUserIDs(userID.(uint))
+	if err != nil {
+		log.Printf("error loading connected users: %v", err)
+	}
+
+	blockedIDs, err := models.GetBlockedUserIDs(userID.(uint))
+	if err != nil {
+		log.Printf("error loading blocked users: %v", err)
+	}
+
+	blocked := make(map[uint]bool, len(blockedIDs))
+	for _, blockedID := range blockedIDs {
+		blocked[blockedID] = true
+	}
+
+	client := &Client{hub: hub, id: newClientID(), conn: conn, send: make(chan []byte, 256), userID: userID.(uint), blocked: blocked}
+	onlinePeers := hub.onlinePeers(peers, blocked)
+
+	// The pumps are counted before registering, once Run accepted the client
+	// Shutdown may already be waiting on them.
+	client.hub.pumps.Add(2)
+	if !client.hub.registerClient(client) {
+		client.hub.pumps.Add(-2)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
+
+	go client.writePump()
+	client.hub.connected(client, peers, onlinePeers)
+	go client.readPump()
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unifriend-api/models"
+)
+
+const (
+	defaultDataExportHourLifespan = 168
+	dataExportQueueSize           = 64
+)
+
+type DataExportQueue interface {
+	Enqueue(dataExport *models.DataExport) error
+}
+
+// DataExporter generates the archives in the background and keeps them in
+// DATA_EXPORT_DIR. Downloads read the file from there, so with more than one
+// replica the directory must be a volume shared by all of them.
+type DataExporter struct {
+	dir       string
+	lifespan  time.Duration
+	jobs      chan *models.DataExport
+	done      chan struct{}
+	startedAt time.Time
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewDataExporter() *DataExporter {
+	dir := os.Getenv("DATA_EXPORT_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "unifriend-exports")
+	}
+
+	lifespan, err := strconv.Atoi(os.Getenv("DATA_EXPORT_HOUR_LIFESPAN"))
+	if err != nil || lifespan <= 0 {
+		lifespan = defaultDataExportHourLifespan
+	}
+
+	return &DataExporter{
+		dir:       dir,
+		lifespan:  time.Duration(lifespan) * time.Hour,
+		jobs:      make(chan *models.DataExport, dataExportQueueSize),
+		done:      make(chan struct{}),
+		startedAt: time.Now(),
+	}
+}
+
+func (e *DataExporter) Enqueue(dataExport *models.DataExport) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return fmt.Errorf("data exporter is shutting down")
+	}
+
+	select {
+	case e.jobs <- dataExport:
+		return nil
+	default:
+		return fmt.Errorf("data export queue is full")
+	}
+}
+
+func (e *DataExporter) Run() {
+	defer close(e.done)
+
+	if failed, err := models.FailInterruptedDataExports(e.startedAt); err != nil {
+		log.Printf("error failing interrupted data exports: %v", err)
+	} else if failed > 0 {
+		log.Printf("marked %d interrupted data exports as failed", failed)
+	}
+
+	for dataExport := range e.jobs {
+		if err := e.Generate(dataExport); err != nil {
+			log.Printf("error generating data export %d: %v", dataExport.ID, err)
+		}
+	}
+}
+
+// Stop closes the queue once. Enqueue holds the same lock, so a request
+// still running during shutdown gets an error instead of sending on a closed
+// channel.
+func (e *DataExporter) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	e.closed = true
+	close(e.jobs)
+}
+
+// Shutdown stops accepting exports and waits for the queued ones to be
+// generated, so none is left marked as processing, or for ctx to expire.
+func (e *DataExporter) Shutdown(ctx context.Context) error {
+	e.Stop()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *DataExporter) Generate(dataExport *models.DataExport) error {
+	if err := dataExport.MarkProcessing(); err != nil {
+		return err
+	}
+
+	filePath, err := e.writeArchive(dataExport)
+	if err != nil {
+		dataExport.MarkFailed()
+		return err
+	}
+
+	return dataExport.MarkReady(filePath, e.lifespan)
+}
+
+func (e *DataExporter) writeArchive(dataExport *models.DataExport) (string, error) {
+	archive, err := models.BuildUserDataArchive(dataExport.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(e.dir, 0o700); err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(e.dir, fmt.Sprintf("user-%d-export-%d.json", dataExport.UserID, dataExport.ID))
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(archive); err != nil {
+		return "", err
+	}
+
+	return filePath, nil
+}
@@ -1,63 +1,448 @@
 package services
 
 import (
+	"context"
 	"log"
+	"sync"
+	"time"
 	"unifriend-api/models"
 )
 
 type Hub struct {
-    clients map[uint]*Client
-    broadcast chan *models.Message
-    register chan *Client
-    unregister chan *Client
+	// clients holds every open socket of each online user connected to this
+	// hub, one per device.
+	clients    map[uint]map[*Client]bool
+	backplane  Backplane
+	deliveries <-chan *Delivery
+	presence   PresenceStore
+	notifier   Notifier
+	reply      chan *reply
+	register   chan *Client
+	unregister chan *Client
+
+	// quit asks Run to close every client, done is closed once Run returned
+	// and pumps counts the read and write pumps still running.
+	quit     chan struct{}
+	quitOnce sync.Once
+	done     chan struct{}
+	pumps    sync.WaitGroup
+}
+
+// reply is a frame addressed to a single client, such as an error caused by
+// something that client sent.
+type reply struct {
+	client *Client
+	data   []byte
 }
 
+// NewHub returns a hub for a single replica, backed by an in-memory backplane
+// and presence store.
 func NewHub() *Hub {
-    return &Hub{
-        broadcast:  make(chan *models.Message),
-        register:   make(chan *Client),
-        unregister: make(chan *Client),
-        clients:    make(map[uint]*Client),
-    }
+	hub, _ := NewHubWithBackplane(NewMemoryBackplane(), NewMemoryPresence())
+	return hub
+}
+
+// NewHubWithBackplane returns a hub sharing deliveries and presence with the
+// other replicas that use the same backplane and presence store.
+func NewHubWithBackplane(backplane Backplane, presence PresenceStore) (*Hub, error) {
+	deliveries, err := backplane.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hub{
+		reply:      make(chan *reply),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		clients:    make(map[uint]map[*Client]bool),
+		backplane:  backplane,
+		deliveries: deliveries,
+		presence:   presence,
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// SetNotifier enables push notifications for users without an open socket.
+// It must be called before the hub starts serving.
+func (h *Hub) SetNotifier(notifier Notifier) {
+	h.notifier = notifier
+}
+
+// NotifyOffline pushes the notification to the user's devices unless they
+// have a socket open on any replica, in which case they already got the event
+// live.
+func (h *Hub) NotifyOffline(userID uint, notification PushNotification) {
+	if h.notifier == nil || !h.isOffline(userID) {
+		return
+	}
+
+	h.notify(userID, notification)
+}
+
+// isOffline treats a user whose presence can't be loaded as offline, a
+// duplicated push is better than a missed one.
+func (h *Hub) isOffline(userID uint) bool {
+	online, err := h.presence.IsOnline(userID)
+	if err != nil {
+		log.Printf("error loading presence of user %d: %v", userID, err)
+		return true
+	}
+
+	return !online
+}
+
+func (h *Hub) notify(userID uint, notification PushNotification) {
+	if err := h.notifier.Notify(userID, notification); err != nil {
+		log.Printf("error notifying user %d: %v", userID, err)
+	}
+}
+
+// IsOnline reports whether the user has a socket open on any replica.
+func (h *Hub) IsOnline(userID uint) bool {
+	online, err := h.presence.IsOnline(userID)
+	if err != nil {
+		log.Printf("error loading presence of user %d: %v", userID, err)
+	}
+
+	return online
+}
+
+// OnlineUsers reports which of the users have a socket open on any replica.
+func (h *Hub) OnlineUsers(userIDs []uint) map[uint]bool {
+	online, err := h.presence.OnlineUsers(userIDs)
+	if err != nil {
+		log.Printf("error loading presence: %v", err)
+		return make(map[uint]bool)
+	}
+
+	return online
 }
 
+// Broadcast sends a new message to the receiver and echoes it to the
+// sender's devices. It publishes straight to the backplane from the caller's
+// goroutine, so the event loop never waits on encoding or I/O for it.
 func (h *Hub) Broadcast(message *models.Message) {
-    h.broadcast <- message
+	h.broadcastFrom(message, "")
+}
+
+// broadcastFrom skips the sender's socket with the origin id, which gets a
+// message.ack instead.
+func (h *Hub) broadcastFrom(message *models.Message, origin string) {
+	frame := newEnvelope(EventMessageNew, "", message)
+
+	h.publish(&Delivery{UserID: message.ReceiverID, Data: frame, SenderID: message.SenderID})
+	h.publish(&Delivery{UserID: message.SenderID, Data: frame, SkipClient: origin})
+
+	if h.notifier == nil || !h.isOffline(message.ReceiverID) {
+		return
+	}
+
+	sender, err := models.GetUserByID(message.SenderID)
+	if err != nil {
+		log.Printf("error loading sender of message %d: %v", message.ID, err)
+		return
+	}
+
+	h.notify(message.ReceiverID, NewMessagePush(message, sender.Name))
+}
+
+// SendToUser delivers a frame to every device of the user.
+func (h *Hub) SendToUser(userID uint, data []byte) {
+	h.publish(&Delivery{UserID: userID, Data: data})
+}
+
+// SendFromUser delivers a frame caused by senderID, unless one of the two
+// users blocked the other.
+func (h *Hub) SendFromUser(userID uint, senderID uint, data []byte) {
+	h.publish(&Delivery{UserID: userID, Data: data, SenderID: senderID})
+}
+
+// SendNotification delivers a notification that was just stored to every
+// device of its recipient.
+func (h *Hub) SendNotification(notification *models.NotificationWithActor) {
+	h.SendFromUser(notification.UserID, notification.ActorID, newEnvelope(EventNotificationNew, "", notification))
+}
+
+// Block makes the sockets of both users drop every event coming from the
+// other one and stop sharing presence, on whichever replica they are.
+func (h *Hub) Block(blockerID uint, blockedID uint) {
+	h.publish(&Delivery{UserID: blockerID, Blocked: blockedID})
+	h.publish(&Delivery{UserID: blockedID, Blocked: blockerID})
 }
 
+// Disconnect closes every socket the user has open, on every replica, e.g.
+// after the account was suspended.
+func (h *Hub) Disconnect(userID uint) {
+	h.publish(&Delivery{UserID: userID, Disconnect: true})
+}
+
+// Unblock lets events flow again between two users that no longer block
+// each other in either direction.
+func (h *Hub) Unblock(userID uint, otherUserID uint) {
+	h.publish(&Delivery{UserID: userID, Unblocked: otherUserID})
+	h.publish(&Delivery{UserID: otherUserID, Unblocked: userID})
+}
 
+// NotifyRead tells the other user of the connection that readerId has read
+// their messages up to messageId.
+func (h *Hub) NotifyRead(connection models.Connection, readerId uint, messageId uint, readAt time.Time) {
+	h.SendFromUser(connection.OtherUserID(readerId), readerId, newEnvelope(EventMessageRead, "", ReadPayload{
+		ConnectionID: connection.ID,
+		MessageID:    messageId,
+		UserID:       readerId,
+		ReadAt:       &readAt,
+	}))
+}
+
+// Run owns the map of connected clients. It only routes frames that are
+// already encoded, everything touching the database, encoding payloads or
+// publishing happens on the goroutine that produced the event.
 func (h *Hub) Run() {
-    for {
-        select {
-        case client := <-h.register:
-            h.clients[client.userID] = client
-        case client := <-h.unregister:
-            if _, ok := h.clients[client.userID]; ok {
-                delete(h.clients, client.userID)
-                close(client.send)
-            }
-        case message := <-h.broadcast:
-            var connection models.Connection
-            if err := models.DB.First(&connection, message.ConnectionID).Error; err != nil {
-                log.Printf("error finding connection: %v", err)
-                continue
-            }
-
-            var recipientID uint
-            if message.SenderID == connection.UserAID {
-                recipientID = connection.UserBID
-            } else {
-                recipientID = connection.UserAID
-            }
-
-            if client, ok := h.clients[recipientID]; ok {
-                select {
-                case client.send <- message:
-                default:
-                    close(client.send)
-                    delete(h.clients, client.userID)
-                }
-            }
-        }
-    }
-}
\ No newline at end of file
+	defer close(h.done)
+	defer h.closeClients()
+
+	for {
+		select {
+		case <-h.quit:
+			return
+		case client := <-h.register:
+			h.add(client)
+		case client := <-h.unregister:
+			h.remove(client)
+		case r := <-h.reply:
+			if h.clients[r.client.userID][r.client] {
+				h.deliver(r.client, r.data)
+			}
+		case d, ok := <-h.deliveries:
+			if !ok {
+				return
+			}
+			h.route(d)
+		}
+	}
+}
+
+// Shutdown sends a close frame to every connected client and waits for their
+// pumps to exit, or for ctx to expire. The HTTP server must be shut down first
+// so no new sockets are opened meanwhile.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.quitOnce.Do(func() { close(h.quit) })
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	pumpsDone := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(pumpsDone)
+	}()
+
+	select {
+	case <-pumpsDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeClients closes the send channel of every client, which makes its
+// write pump send a close frame and hang up.
+func (h *Hub) closeClients() {
+	for _, devices := range h.clients {
+		for client := range devices {
+			h.remove(client)
+		}
+	}
+}
+
+// registerClient, unregisterClient and replyTo hand events to Run and give up
+// once the hub has shut down instead of blocking forever.
+func (h *Hub) registerClient(client *Client) bool {
+	select {
+	case h.register <- client:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+func (h *Hub) unregisterClient(client *Client) {
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) replyTo(client *Client, data []byte) {
+	select {
+	case h.reply <- &reply{client: client, data: data}:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) add(client *Client) {
+	devices, online := h.clients[client.userID]
+	if !online {
+		devices = make(map[*Client]bool)
+		h.clients[client.userID] = devices
+	}
+	devices[client] = true
+}
+
+func (h *Hub) remove(client *Client) {
+	devices, ok := h.clients[client.userID]
+	if !ok || !devices[client] {
+		return
+	}
+
+	delete(devices, client)
+	close(client.send)
+
+	if len(devices) == 0 {
+		delete(h.clients, client.userID)
+	}
+}
+
+// connected runs on the goroutine serving the socket once the hub accepted
+// it. Peers only care about the user going online on their first socket,
+// wherever it is, but every new device needs to know which of its peers are
+// already online.
+func (h *Hub) connected(client *Client, peers []uint, onlinePeers []uint) {
+	first, err := h.presence.Connect(client.userID, client.id)
+	if err != nil {
+		log.Printf("error saving presence of user %d: %v", client.userID, err)
+	}
+
+	if first {
+		h.saveLastSeen(client.userID, time.Now())
+		h.announcePresence(client.userID, peers, PresencePayload{UserID: client.userID, Online: true})
+	}
+
+	for _, peerID := range onlinePeers {
+		h.replyTo(client, newEnvelope(EventPresence, "", PresencePayload{UserID: peerID, Online: true}))
+	}
+}
+
+// disconnected runs once the socket's read pump stopped, whichever side
+// closed it. The peers are loaded again since they may have changed while
+// the socket was open.
+func (h *Hub) disconnected(client *Client) {
+	last, err := h.presence.Disconnect(client.userID, client.id)
+	if err != nil {
+		log.Printf("error saving presence of user %d: %v", client.userID, err)
+	}
+
+	if !last {
+		return
+	}
+
+	lastSeen := time.Now()
+	h.saveLastSeen(client.userID, lastSeen)
+
+	peers, err := models.GetConnectedUserIDs(client.userID)
+	if err != nil {
+		log.Printf("error loading connected users: %v", err)
+		return
+	}
+
+	h.announcePresence(client.userID, peers, PresencePayload{UserID: client.userID, Online: false, LastSeenAt: &lastSeen})
+}
+
+// onlinePeers returns the peers with a socket open, leaving out the blocked
+// ones. It must run before the client is registered, blocked belongs to the
+// hub goroutine afterwards.
+func (h *Hub) onlinePeers(peers []uint, blocked map[uint]bool) []uint {
+	presence := h.OnlineUsers(peers)
+
+	online := make([]uint, 0, len(peers))
+	for _, peerID := range peers {
+		if !blocked[peerID] && presence[peerID] {
+			online = append(online, peerID)
+		}
+	}
+
+	return online
+}
+
+func (h *Hub) saveLastSeen(userID uint, lastSeen time.Time) {
+	if err := models.UpdateLastSeen(userID, lastSeen); err != nil {
+		log.Printf("error saving last seen of user %d: %v", userID, err)
+	}
+}
+
+// announcePresence tells the peers of the user whether they are online.
+func (h *Hub) announcePresence(userID uint, peers []uint, payload PresencePayload) {
+	frame := newEnvelope(EventPresence, "", payload)
+
+	for _, peerID := range peers {
+		h.publish(&Delivery{UserID: peerID, Data: frame, SenderID: userID})
+	}
+}
+
+func (h *Hub) publish(delivery *Delivery) {
+	if err := h.backplane.Publish(delivery); err != nil {
+		log.Printf("error publishing delivery to user %d: %v", delivery.UserID, err)
+	}
+}
+
+func (h *Hub) route(d *Delivery) {
+	switch {
+	case d.Disconnect:
+		for client := range h.clients[d.UserID] {
+			h.remove(client)
+		}
+	case d.Blocked != 0:
+		h.block(d.UserID, d.Blocked)
+	case d.Unblocked != 0:
+		h.unblock(d.UserID, d.Unblocked)
+	default:
+		h.deliverToUser(d)
+	}
+}
+
+// deliverToUser sends the frame to every device of the user connected to this
+// hub except the skipped one and the ones that blocked the sender.
+func (h *Hub) deliverToUser(d *Delivery) {
+	for client := range h.clients[d.UserID] {
+		if d.SkipClient != "" && client.id == d.SkipClient {
+			continue
+		}
+
+		if d.SenderID != 0 && client.blocked[d.SenderID] {
+			continue
+		}
+
+		h.deliver(client, d.Data)
+	}
+}
+
+// block makes the user's sockets drop the other user's events, presence
+// included, since blocking also removed their connection.
+func (h *Hub) block(userID uint, otherUserID uint) {
+	for client := range h.clients[userID] {
+		if client.blocked == nil {
+			client.blocked = make(map[uint]bool)
+		}
+		client.blocked[otherUserID] = true
+	}
+}
+
+func (h *Hub) unblock(userID uint, otherUserID uint) {
+	for client := range h.clients[userID] {
+		delete(client.blocked, otherUserID)
+	}
+}
+
+// deliver drops a client whose buffer is full instead of blocking the hub on
+// a slow socket.
+func (h *Hub) deliver(client *Client, data []byte) {
+	select {
+	case client.send <- data:
+	default:
+		h.remove(client)
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"unifriend-api/models"
+)
+
+// The benchmarks drive the hub with in-process clients that have no socket,
+// so they measure routing alone. Messages are sent in batches smaller than the
+// backplane buffer and each batch is waited on, which gives the throughput the
+// hub sustains without dropping anything.
+const benchmarkBatchSize = 256
+
+// startBenchmarkHub shuts the hub down when the benchmark ends, which closes
+// the send channel of every client and so stops their readers too.
+func startBenchmarkHub(b *testing.B, users int) (*Hub, *sync.WaitGroup) {
+	hub := NewHub()
+	go hub.Run()
+
+	b.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := hub.Shutdown(ctx); err != nil {
+			b.Errorf("hub did not shut down: %v", err)
+		}
+	})
+
+	var received sync.WaitGroup
+
+	for i := 1; i <= users; i++ {
+		client := &Client{hub: hub, id: newClientID(), send: make(chan []byte, 256), userID: uint(i)}
+		hub.register <- client
+
+		go func() {
+			for range client.send {
+				received.Done()
+			}
+		}()
+	}
+
+	return hub, &received
+}
+
+func BenchmarkHubBroadcast(b *testing.B) {
+	for _, users := range []int{1000, 5000, 10000} {
+		b.Run(fmt.Sprintf("clients=%d", users), func(b *testing.B) {
+			hub, received := startBenchmarkHub(b, users)
+
+			b.ResetTimer()
+
+			for sent := 0; sent < b.N; sent += benchmarkBatchSize {
+				batch := min(benchmarkBatchSize, b.N-sent)
+
+				// Both the receiver and the sender's own device get a copy.
+				received.Add(batch * 2)
+
+				for i := 0; i < batch; i++ {
+					sender := uint((sent+i)%users) + 1
+					message := &models.Message{
+						ID:           uint(sent + i + 1),
+						ConnectionID: 1,
+						SenderID:     sender,
+						ReceiverID:   sender%uint(users) + 1,
+						Content:      "hello",
+					}
+					hub.Broadcast(message)
+				}
+
+				received.Wait()
+			}
+
+			b.StopTimer()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "msgs/s")
+		})
+	}
+}
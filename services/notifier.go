@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"unicode/utf8"
+	"unifriend-api/models"
+)
+
+const (
+	PushMessageNew                = "message.new"
+	PushConnectionRequestReceived = "connection_request.received"
+	PushConnectionRequestAccepted = "connection_request.accepted"
+
+	pushPreviewLength = 120
+)
+
+// PushNotification is what the service worker receives and shows.
+type PushNotification struct {
+	Type  string                 `json:"type"`
+	Title string                 `json:"title"`
+	Body  string                 `json:"body"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier sends notifications to the devices a user registered for push.
+type Notifier interface {
+	Notify(userID uint, notification PushNotification) error
+}
+
+func NewMessagePush(message *models.Message, senderName string) PushNotification {
+	body := message.Content
+	if utf8.RuneCountInString(body) > pushPreviewLength {
+		body = string([]rune(body)[:pushPreviewLength]) + "…"
+	}
+
+	return PushNotification{
+		Type:  PushMessageNew,
+		Title: senderName,
+		Body:  body,
+		Data: map[string]interface{}{
+			"connection_id": message.ConnectionID,
+			"message_id":    message.ID,
+		},
+	}
+}
+
+func NewConnectionRequestPush(connectionRequest *models.ConnectionRequest, requesterName string) PushNotification {
+	return PushNotification{
+		Type:  PushConnectionRequestReceived,
+		Title: "New connection request",
+		Body:  fmt.Sprintf("%s wants to connect with you", requesterName),
+		Data: map[string]interface{}{
+			"connection_request_id": connectionRequest.ID,
+			"user_id":               connectionRequest.RequestingUserID,
+		},
+	}
+}
+
+func NewConnectionAcceptedPush(connection *models.Connection, accepterName string) PushNotification {
+	return PushNotification{
+		Type:  PushConnectionRequestAccepted,
+		Title: "Connection request accepted",
+		Body:  fmt.Sprintf("%s accepted your connection request", accepterName),
+		Data: map[string]interface{}{
+			"connection_id": connection.ID,
+			"user_id":       connection.UserBID,
+		},
+	}
+}
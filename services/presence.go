@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultPresenceKeyPrefix = "unifriend:presence:"
+
+	// presenceTTL is how long a socket counts as open without being
+	// refreshed. Sockets refresh on every ping, so only the ones of a replica
+	// that died without closing them expire.
+	presenceTTL = 2 * pongWait
+)
+
+// PresenceStore tracks the sockets each user has open on every replica of the
+// deployment. The client tells it when a socket was registered and when it
+// closed, always off the hub goroutine.
+type PresenceStore interface {
+	// Connect reports whether this is the first socket of the user.
+	Connect(userID uint, socketID string) (bool, error)
+	// Disconnect reports whether the user closed their last socket.
+	Disconnect(userID uint, socketID string) (bool, error)
+	// Refresh keeps an open socket from expiring.
+	Refresh(userID uint, socketID string) error
+	IsOnline(userID uint) (bool, error)
+	OnlineUsers(userIDs []uint) (map[uint]bool, error)
+	Close() error
+}
+
+// NewPresenceStore keeps presence in Redis when REDIS_URL is set, so every
+// replica agrees on who is online, and falls back to memory otherwise.
+func NewPresenceStore() (PresenceStore, error) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return NewMemoryPresence(), nil
+	}
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRedisPresence(redis.NewClient(options), defaultPresenceKeyPrefix), nil
+}
+
+type MemoryPresence struct {
+	mu      sync.RWMutex
+	sockets map[uint]map[string]bool
+}
+
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{sockets: make(map[uint]map[string]bool)}
+}
+
+func (p *MemoryPresence) Connect(userID uint, socketID string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sockets, ok := p.sockets[userID]
+	if !ok {
+		sockets = make(map[string]bool)
+		p.sockets[userID] = sockets
+	}
+	sockets[socketID] = true
+
+	return len(sockets) == 1, nil
+}
+
+func (p *MemoryPresence) Disconnect(userID uint, socketID string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sockets := p.sockets[userID]
+	if !sockets[socketID] {
+		return false, nil
+	}
+
+	delete(sockets, socketID)
+	if len(sockets) > 0 {
+		return false, nil
+	}
+
+	delete(p.sockets, userID)
+	return true, nil
+}
+
+func (p *MemoryPresence) Refresh(userID uint, socketID string) error {
+	return nil
+}
+
+func (p *MemoryPresence) IsOnline(userID uint) (bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.sockets[userID]) > 0, nil
+}
+
+func (p *MemoryPresence) OnlineUsers(userIDs []uint) (map[uint]bool, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	online := make(map[uint]bool, len(userIDs))
+	for _, userID := range userIDs {
+		online[userID] = len(p.sockets[userID]) > 0
+	}
+
+	return online, nil
+}
+
+func (p *MemoryPresence) Close() error {
+	return nil
+}
+
+// RedisPresence keeps a sorted set per user with the id of each open socket,
+// scored by the time it expires unless refreshed.
+type RedisPresence struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisPresence(client *redis.Client, prefix string) *RedisPresence {
+	return &RedisPresence{client: client, prefix: prefix}
+}
+
+func (p *RedisPresence) Connect(userID uint, socketID string) (bool, error) {
+	ctx := context.Background()
+	key := p.key(userID)
+	now := time.Now()
+
+	var sockets *redis.IntCmd
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", presenceScore(now))
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Add(presenceTTL).UnixMilli()), Member: socketID})
+		sockets = pipe.ZCard(ctx, key)
+		pipe.PExpire(ctx, key, presenceTTL)
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return sockets.Val() == 1, nil
+}
+
+func (p *RedisPresence) Disconnect(userID uint, socketID string) (bool, error) {
+	ctx := context.Background()
+	key := p.key(userID)
+
+	var removed, sockets *redis.IntCmd
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, key, socketID)
+		pipe.ZRemRangeByScore(ctx, key, "-inf", presenceScore(time.Now()))
+		sockets = pipe.ZCard(ctx, key)
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return removed.Val() == 1 && sockets.Val() == 0, nil
+}
+
+func (p *RedisPresence) Refresh(userID uint, socketID string) error {
+	ctx := context.Background()
+	key := p.key(userID)
+
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAddXX(ctx, key, redis.Z{Score: float64(time.Now().Add(presenceTTL).UnixMilli()), Member: socketID})
+		pipe.PExpire(ctx, key, presenceTTL)
+		return nil
+	})
+
+	return err
+}
+
+func (p *RedisPresence) IsOnline(userID uint) (bool, error) {
+	online, err := p.OnlineUsers([]uint{userID})
+	return online[userID], err
+}
+
+func (p *RedisPresence) OnlineUsers(userIDs []uint) (map[uint]bool, error) {
+	online := make(map[uint]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return online, nil
+	}
+
+	ctx := context.Background()
+	min := "(" + presenceScore(time.Now())
+
+	counts := make([]*redis.IntCmd, len(userIDs))
+	_, err := p.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, userID := range userIDs {
+			counts[i] = pipe.ZCount(ctx, p.key(userID), min, "+inf")
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		online[userID] = counts[i].Val() > 0
+	}
+
+	return online, nil
+}
+
+func (p *RedisPresence) Close() error {
+	return p.client.Close()
+}
+
+func (p *RedisPresence) key(userID uint) string {
+	return p.prefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+func presenceScore(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Every frame on the chat socket, in either direction, is an Envelope. ID is
+// set by the client on frames it wants acknowledged and echoed back on the
+// matching message.ack or error frame.
+type Envelope struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+const (
+	EventMessageNew  = "message.new"
+	EventMessageAck  = "message.ack"
+	EventMessageRead = "message.read"
+	EventTypingStart = "typing.start"
+	EventTypingStop  = "typing.stop"
+	EventPresence    = "presence"
+	EventError       = "error"
+
+	EventNotificationNew = "notification.new"
+)
+
+const (
+	ErrorInvalidFrame       = "invalid_frame"
+	ErrorUnknownType        = "unknown_type"
+	ErrorInvalidContent     = "invalid_content"
+	ErrorConnectionNotFound = "connection_not_found"
+	ErrorMessageNotFound    = "message_not_found"
+	ErrorInternal           = "internal_error"
+)
+
+type NewMessagePayload struct {
+	ConnectionID uint   `json:"connection_id"`
+	Content      string `json:"content"`
+}
+
+type TypingPayload struct {
+	ConnectionID uint `json:"connection_id"`
+	UserID       uint `json:"user_id,omitempty"`
+}
+
+type ReadPayload struct {
+	ConnectionID uint       `json:"connection_id"`
+	MessageID    uint       `json:"message_id"`
+	UserID       uint       `json:"user_id,omitempty"`
+	ReadAt       *time.Time `json:"read_at,omitempty"`
+}
+
+type PresencePayload struct {
+	UserID     uint       `json:"user_id"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newEnvelope(eventType string, id string, payload interface{}) []byte {
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("error encoding %s payload: %v", eventType, err)
+		return nil
+	}
+
+	data, err := json.Marshal(Envelope{Type: eventType, ID: id, Payload: rawPayload})
+	if err != nil {
+		log.Printf("error encoding %s frame: %v", eventType, err)
+		return nil
+	}
+
+	return data
+}
+
+func newErrorEnvelope(id string, code string, message string) []byte {
+	return newEnvelope(EventError, id, ErrorPayload{Code: code, Message: message})
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// defaultPushHosts are the push services of the browsers we support. A
+// subscription endpoint must be on one of them or on a subdomain.
+var defaultPushHosts = []string{
+	"fcm.googleapis.com",
+	"push.services.mozilla.com",
+	"push.apple.com",
+	"notify.windows.com",
+}
+
+var ErrInvalidPushEndpoint = errors.New("endpoint must be an https URL of a known push service")
+
+// pushHosts returns the push services endpoints may point to. PUSH_ALLOWED_HOSTS
+// replaces the defaults with a comma separated list.
+func pushHosts() []string {
+	allowed := os.Getenv("PUSH_ALLOWED_HOSTS")
+	if allowed == "" {
+		return defaultPushHosts
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(allowed, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// ValidatePushEndpoint makes sure the server only ever posts pushes to a known
+// push service over https, never to an address picked by the user.
+func ValidatePushEndpoint(endpoint string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "https" || parsed.User != nil {
+		return ErrInvalidPushEndpoint
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range pushHosts() {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+
+	return ErrInvalidPushEndpoint
+}
+
+// newPushHTTPClient refuses to connect to loopback, private and link-local
+// addresses, so a push service name resolving to one can't reach the
+// internal network either.
+func newPushHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: pushRequestTimeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to send push to %s", host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: pushRequestTimeout, Transport: transport}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
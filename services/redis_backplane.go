@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisBackplane struct {
+	client  *redis.Client
+	channel string
+
+	mu     sync.Mutex
+	pubsub []*redis.PubSub
+	closed bool
+
+	// done is closed by Close, so a subscriber whose hub stopped reading
+	// doesn't wait on it forever.
+	done chan struct{}
+}
+
+func NewRedisBackplane(client *redis.Client, channel string) *RedisBackplane {
+	return &RedisBackplane{client: client, channel: channel, done: make(chan struct{})}
+}
+
+func (b *RedisBackplane) Publish(delivery *Delivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(context.Background(), b.channel, data).Err()
+}
+
+// Subscribe waits for Redis to confirm the subscription, so nothing published
+// after it returns is missed.
+func (b *RedisBackplane) Subscribe() (<-chan *Delivery, error) {
+	ctx := context.Background()
+
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.pubsub = append(b.pubsub, pubsub)
+	b.mu.Unlock()
+
+	deliveries := make(chan *Delivery, memoryBackplaneBuffer)
+
+	go func() {
+		defer close(deliveries)
+
+		for message := range pubsub.Channel() {
+			var delivery Delivery
+			if err := json.Unmarshal([]byte(message.Payload), &delivery); err != nil {
+				log.Printf("error decoding backplane delivery: %v", err)
+				continue
+			}
+
+			select {
+			case deliveries <- &delivery:
+			case <-b.done:
+				return
+			}
+		}
+	}()
+
+	return deliveries, nil
+}
+
+func (b *RedisBackplane) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.closed = true
+	close(b.done)
+	for _, pubsub := range b.pubsub {
+		pubsub.Close()
+	}
+	b.pubsub = nil
+	b.mu.Unlock()
+
+	return b.client.Close()
+}
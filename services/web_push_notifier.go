@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unifriend-api/models"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+)
+
+const (
+	defaultPushTTLSeconds = 24 * 60 * 60
+	pushRequestTimeout    = 10 * time.Second
+)
+
+// WebPushNotifier delivers notifications with the Web Push protocol, signing
+// requests with the server's VAPID key pair.
+type WebPushNotifier struct {
+	publicKey  string
+	privateKey string
+	subject    string
+	ttl        int
+	client     *http.Client
+
+	// pending counts the pushes still being sent, Notify returns before the
+	// push services answer. mu keeps Notify from adding to it once Shutdown
+	// started waiting.
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+func NewWebPushNotifier() (*WebPushNotifier, error) {
+	return NewWebPushNotifierWithClient(newPushHTTPClient())
+}
+
+// NewWebPushNotifierWithClient sends pushes through the given client instead
+// of one that only reaches public addresses.
+func NewWebPushNotifierWithClient(client *http.Client) (*WebPushNotifier, error) {
+	publicKey := os.Getenv("VAPID_PUBLIC_KEY")
+	privateKey := os.Getenv("VAPID_PRIVATE_KEY")
+	subject := os.Getenv("VAPID_SUBJECT")
+
+	if publicKey == "" || privateKey == "" || subject == "" {
+		return nil, errors.New("VAPID_PUBLIC_KEY, VAPID_PRIVATE_KEY and VAPID_SUBJECT must be set")
+	}
+
+	ttl, err := strconv.Atoi(os.Getenv("PUSH_TTL_SECONDS"))
+	if err != nil || ttl <= 0 {
+		ttl = defaultPushTTLSeconds
+	}
+
+	return &WebPushNotifier{
+		publicKey:  publicKey,
+		privateKey: privateKey,
+		subject:    subject,
+		ttl:        ttl,
+		client:     client,
+	}, nil
+}
+
+func VAPIDPublicKey() string {
+	return os.Getenv("VAPID_PUBLIC_KEY")
+}
+
+func (n *WebPushNotifier) Notify(userID uint, notification PushNotification) error {
+	deviceTokens, err := models.GetPushDeviceTokens(userID)
+	if err != nil {
+		return err
+	}
+
+	if len(deviceTokens) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return errors.New("push notifier is shutting down")
+	}
+
+	for _, deviceToken := range deviceTokens {
+		n.pending.Add(1)
+		// webpush pads the payload in place, so every send gets its own copy.
+		go func(deviceToken models.DeviceToken, payload []byte) {
+			defer n.pending.Done()
+			n.send(deviceToken, payload)
+		}(deviceToken, append([]byte(nil), payload...))
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting pushes and waits for the ones already handed to a
+// push service to be done, or for ctx to expire.
+func (n *WebPushNotifier) Shutdown(ctx context.Context) error {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *WebPushNotifier) send(deviceToken models.DeviceToken, payload []byte) {
+	// Devices registered before endpoints were validated may point anywhere.
+	if err := ValidatePushEndpoint(deviceToken.Endpoint); err != nil {
+		log.Printf("not sending push to device %d: %v", deviceToken.ID, err)
+		return
+	}
+
+	subscription := &webpush.Subscription{
+		Endpoint: deviceToken.Endpoint,
+		Keys: webpush.Keys{
+			Auth:   deviceToken.Auth,
+			P256dh: deviceToken.P256dh,
+		},
+	}
+
+	response, err := webpush.SendNotification(payload, subscription, &webpush.Options{
+		HTTPClient:      n.client,
+		Subscriber:      n.subject,
+		VAPIDPublicKey:  n.publicKey,
+		VAPIDPrivateKey: n.privateKey,
+		TTL:             n.ttl,
+		Urgency:         webpush.UrgencyHigh,
+	})
+	if err != nil {
+		log.Printf("error sending push to device %d: %v", deviceToken.ID, err)
+		return
+	}
+	defer response.Body.Close()
+
+	// The browser unsubscribed or the subscription expired, it will never
+	// accept a push again.
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone {
+		if err := models.DeleteDeviceTokenByEndpoint(deviceToken.Endpoint); err != nil {
+			log.Printf("error removing expired device %d: %v", deviceToken.ID, err)
+		}
+		return
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		log.Printf("push service rejected push to device %d with status %d", deviceToken.ID, response.StatusCode)
+	}
+}
@@ -0,0 +1,636 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"unifriend-api/handlers"
+	"unifriend-api/models"
+	"unifriend-api/tests/factory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRequest(method string, path string, userID uint, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(userID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func auditActions(targetType string, targetID uint) []string {
+	auditLogs, _, _ := models.GetAuditLogs(models.AuditLogFilter{TargetType: targetType, TargetID: targetID})
+
+	actions := make([]string, 0)
+	for _, auditLog := range auditLogs {
+		actions = append(actions, auditLog.Action)
+	}
+
+	return actions
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	rec := adminRequest("GET", "/api/admin/users", user.ID, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req, _ := http.NewRequest("GET", "/api/admin/users", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAdminSearchUsers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	user := factory.UserFactory()
+	user.Name = "Ada Lovelace"
+	models.DB.Create(&user)
+
+	other := factory.UserFactory()
+	other.Name = "Grace Hopper"
+	models.DB.Create(&other)
+
+	rec := adminRequest("GET", "/api/admin/users?q=lovelace", admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Ada Lovelace")
+	assert.NotContains(t, rec.Body.String(), "Grace Hopper")
+	assert.NotContains(t, rec.Body.String(), "password")
+
+	rec = adminRequest("GET", "/api/admin/users?status=2", admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": [], "has_more": false}`, rec.Body.String())
+
+	rec = adminRequest("GET", "/api/admin/users?status=7", admin.ID, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminSuspendAndReactivateUser(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	user := factory.UserFactory()
+	models.DB.Create(&admin)
+	models.DB.Create(&user)
+
+	userToken := factory.GetUserFactoryToken(user.ID)
+
+	rec := adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/suspend", user.ID), admin.ID, map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/suspend", admin.ID), admin.ID, map[string]string{"reason": "test"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/suspend", user.ID), admin.ID, map[string]string{"reason": "spam"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var suspended models.User
+	models.DB.First(&suspended, user.ID)
+	assert.Equal(t, models.UserStatusSuspended, suspended.Status)
+
+	req, _ := http.NewRequest("GET", "/api/users/me", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: userToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/suspend", user.ID), admin.ID, map[string]string{"reason": "spam"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/reactivate", user.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/reactivate", user.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/reactivate", user.ID+100), admin.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	assert.Equal(t, []string{models.AuditUserReactivated, models.AuditUserSuspended}, auditActions(models.AuditTargetUser, user.ID))
+}
+
+func TestAdminSuspendClosesChatSockets(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	handlers.SetHub(hub)
+	defer handlers.SetHub(nil)
+
+	admin := factory.AdminUserFactory()
+	user := factory.UserFactory()
+	models.DB.Create(&admin)
+	models.DB.Create(&user)
+
+	conn := DialChat(t, server, user.ID)
+	defer conn.Close()
+
+	rec := adminRequest("POST", fmt.Sprintf("/api/admin/users/%d/suspend", user.ID), admin.ID, map[string]string{"reason": "spam"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "timeout")
+}
+
+func TestAdminViewUserImagesIsAudited(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	user := factory.UserFactory()
+	models.DB.Create(&admin)
+	models.DB.Create(&user)
+
+	rec := adminRequest("GET", fmt.Sprintf("/api/admin/users/%d/images", user.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), user.Images[0].ImageUrl)
+
+	assert.Equal(t, []string{models.AuditUserImagesViewed}, auditActions(models.AuditTargetUser, user.ID))
+}
+
+func TestAdminViewUserIsAudited(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	user := factory.UserFactory()
+	models.DB.Create(&admin)
+	models.DB.Create(&user)
+
+	rec := adminRequest("GET", "/api/admin/users?q="+url.QueryEscape(user.Name), admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest("GET", fmt.Sprintf("/api/admin/users/%d", user.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), user.Email)
+
+	rec = adminRequest("GET", fmt.Sprintf("/api/admin/users/%d", user.ID+100), admin.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	assert.Equal(t, []string{models.AuditUserViewed}, auditActions(models.AuditTargetUser, user.ID))
+	assert.Empty(t, auditActions(models.AuditTargetUser, user.ID+100))
+
+	searches, _, _ := models.GetAuditLogs(models.AuditLogFilter{Action: models.AuditUsersSearched})
+	if assert.Len(t, searches, 1) {
+		assert.Equal(t, admin.ID, searches[0].AdminID)
+		assert.Equal(t, user.Name, searches[0].Details["query"])
+	}
+}
+
+func TestAdminReviewReport(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	connection := factory.ConnectionFactory()
+	models.DB.Create(&connection)
+
+	message := models.Message{ConnectionID: connection.ID, SenderID: connection.UserBID, ReceiverID: connection.UserAID, Content: "rude"}
+	models.DB.Create(&message)
+
+	report, err := models.CreateReport(connection.UserAID, connection.UserBID, models.ReportReasonHarassment, "", []uint{message.ID})
+	assert.NoError(t, err)
+
+	rec := adminRequest("GET", "/api/admin/reports?status=open", admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), connection.UserB.Name)
+
+	rec = adminRequest("GET", fmt.Sprintf("/api/admin/reports/%d", report.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "rude")
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/reports/%d", report.ID), admin.ID, map[string]string{"status": "open"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/reports/%d", report.ID), admin.ID, map[string]string{"status": "resolved", "resolution": "warned"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/reports/%d", report.ID), admin.ID, map[string]string{"status": "dismissed"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/reports/%d", report.ID+100), admin.ID, map[string]string{"status": "dismissed"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var reviewed models.Report
+	models.DB.First(&reviewed, report.ID)
+	assert.Equal(t, models.ReportStatusResolved, reviewed.Status)
+	assert.Equal(t, "warned", reviewed.Resolution)
+	if assert.NotNil(t, reviewed.ReviewedByID) {
+		assert.Equal(t, admin.ID, *reviewed.ReviewedByID)
+	}
+
+	assert.Equal(t, []string{models.AuditReportReviewed, models.AuditReportViewed}, auditActions(models.AuditTargetReport, report.ID))
+
+	listings, _, _ := models.GetAuditLogs(models.AuditLogFilter{Action: models.AuditReportsListed})
+	if assert.Len(t, listings, 1) {
+		assert.Equal(t, uint(0), listings[0].TargetID)
+		assert.Equal(t, "open", listings[0].Details["status"])
+	}
+}
+
+func TestAdminManageMajors(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	rec := adminRequest("POST", "/api/admin/majors", admin.ID, map[string]string{"name": "Computer Science"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var response struct {
+		Data models.Major `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	major := response.Data
+
+	rec = adminRequest("POST", "/api/admin/majors", admin.ID, map[string]string{"name": "computer science"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/majors/%d", major.ID), admin.ID, map[string]string{"name": "Informatics"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	details := map[string]interface{}{"name": "Informatics", "previous_name": "Computer Science"}
+	auditLogs, _, _ := models.GetAuditLogs(models.AuditLogFilter{Action: models.AuditMajorUpdated})
+	if assert.Len(t, auditLogs, 1) {
+		assert.Equal(t, models.AuditDetails(details), auditLogs[0].Details)
+		assert.Equal(t, admin.Name, auditLogs[0].AdminName)
+	}
+
+	student := factory.UserFactory()
+	models.DB.Create(&student)
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/majors/%d", student.MajorID), admin.ID, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/majors/%d", major.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/majors/%d", major.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	assert.Equal(t, []string{models.AuditMajorDeleted, models.AuditMajorUpdated, models.AuditMajorCreated}, auditActions(models.AuditTargetMajor, major.ID))
+}
+
+func TestAdminManageEmailDomains(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	rec := adminRequest("POST", "/api/admin/email-domains", admin.ID, map[string]string{"institution": "Example University", "domain": "not a domain"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest("POST", "/api/admin/email-domains", admin.ID, map[string]string{"institution": "Example University", "domain": "Example.EDU"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.True(t, models.EmailDomainExists("example.edu"))
+
+	var response struct {
+		Data models.EmailDomains `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+
+	rec = adminRequest("POST", "/api/admin/email-domains", admin.ID, map[string]string{"institution": "Other", "domain": "example.edu"})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/email-domains/%d", response.Data.ID), admin.ID, map[string]string{"institution": "Example University", "domain": "mail.example.edu"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, models.EmailDomainExists("mail.example.edu"))
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/email-domains/%d", response.Data.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.False(t, models.EmailDomainExists("mail.example.edu"))
+
+	assert.Len(t, auditActions(models.AuditTargetEmailDomain, response.Data.ID), 3)
+}
+
+func TestAdminManageQuizContent(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	rec := adminRequest("POST", "/api/admin/quizzes", admin.ID, map[string]string{"title": "Hobbies", "description": "What you like to do"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var quizResponse struct {
+		Data models.QuizTable `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &quizResponse)
+	quizID := quizResponse.Data.ID
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/questions", quizID), admin.ID, map[string]interface{}{
+		"text":    "Favourite sport?",
+		"options": []string{"Football", "Chess"},
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var questionResponse struct {
+		Data models.QuestionTable `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &questionResponse)
+	question := questionResponse.Data
+	assert.Len(t, question.Options, 2)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/questions/%d/options", question.ID), admin.ID, map[string]string{"text": "Swimming"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/questions/%d", question.ID), admin.ID, map[string]string{"text": "Favourite activity?"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/options/%d", question.Options[1].ID), admin.ID, map[string]string{"text": "Board games"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	quiz, err := models.GetQuizWithQuestions(quizID)
+	assert.NoError(t, err)
+	if assert.Len(t, quiz.Questions, 1) && assert.Len(t, quiz.Questions[0].Options, 3) {
+		assert.Equal(t, "Favourite activity?", quiz.Questions[0].Text)
+		assert.Equal(t, "Board games", quiz.Questions[0].Options[1].Text)
+	}
+
+	student := factory.UserFactory()
+	models.DB.Create(&student)
+	models.DB.Create(&models.UserResponse{UserID: student.ID, QuestionID: question.ID, OptionID: question.Options[0].ID})
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/options/%d", question.Options[0].ID), admin.ID, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/quizzes/%d", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/options/%d", question.Options[1].ID), admin.ID, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	models.DB.Where("user_id = ?", student.ID).Delete(&models.UserResponse{})
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/quizzes/%d", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	var questions int64
+	models.DB.Model(&models.QuestionTable{}).Where("quiz_id = ?", quizID).Count(&questions)
+	assert.Equal(t, int64(0), questions)
+
+	rec = adminRequest("GET", "/api/admin/audit-logs?target_type=quiz", admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{models.AuditQuizDeleted, models.AuditQuizCreated}, auditActions(models.AuditTargetQuiz, quizID))
+}
+
+func TestAdminPublishQuiz(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	student := factory.UserFactory()
+	models.DB.Create(&student)
+
+	rec := adminRequest("POST", "/api/admin/quizzes", admin.ID, map[string]string{"title": "Hobbies", "description": "What you like to do"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var quizResponse struct {
+		Data models.QuizTable `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &quizResponse)
+	quizID := quizResponse.Data.ID
+	assert.Equal(t, models.QuizStatusDraft, quizResponse.Data.Status)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/publish", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/questions", quizID), admin.ID, map[string]interface{}{
+		"text":    "Favourite sport?",
+		"options": []string{"Football"},
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var questionResponse struct {
+		Data models.QuestionTable `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &questionResponse)
+	question := questionResponse.Data
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/publish", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var validationResponse struct {
+		Problems []string `json:"problems"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &validationResponse)
+	assert.Len(t, validationResponse.Problems, 1)
+
+	// Draft quizzes are not shown to users.
+	rec = adminRequest("GET", "/api/questions", student.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "Favourite sport?")
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/questions/%d/options", question.ID), admin.ID, map[string]string{"text": "Chess"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/publish", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/publish", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("GET", "/api/questions", student.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Favourite sport?")
+
+	// A published quiz can not lose the options it needs, nor take a question
+	// without enough of them.
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/options/%d", question.Options[0].ID), admin.ID, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var options int64
+	models.DB.Model(&models.OptionTable{}).Where("question_id = ?", question.ID).Count(&options)
+	assert.Equal(t, int64(2), options)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/questions", quizID), admin.ID, map[string]interface{}{
+		"text": "Favourite food?",
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/questions/%d", question.ID), admin.ID, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/unpublish", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest("POST", fmt.Sprintf("/api/admin/quizzes/%d/unpublish", quizID), admin.ID, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = adminRequest("DELETE", fmt.Sprintf("/api/admin/options/%d", question.Options[0].ID), admin.ID, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	assert.Equal(t, []string{models.AuditQuizUnpublished, models.AuditQuizPublished, models.AuditQuizCreated}, auditActions(models.AuditTargetQuiz, quizID))
+}
+
+func TestAdminQuizContentChangesBumpVersion(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	student := factory.UserFactory()
+	models.DB.Create(&student)
+
+	quiz, err := models.CreateQuiz(admin.ID, "Hobbies", "What you like to do")
+	assert.NoError(t, err)
+
+	question, err := models.CreateQuestion(admin.ID, quiz.ID, "Favourite sport?", []string{"Football", "Chess", "Swimming"})
+	assert.NoError(t, err)
+
+	_, err = models.PublishQuiz(admin.ID, quiz.ID)
+	assert.NoError(t, err)
+
+	version := func() int {
+		current, _ := models.GetQuizByID(quiz.ID)
+		return current.Version
+	}
+
+	complete := func() {
+		models.DB.Where("user_id = ? AND quiz_id = ?", student.ID, quiz.ID).Delete(&models.QuizCompletion{})
+		models.DB.Create(&models.QuizCompletion{UserID: student.ID, QuizID: quiz.ID, QuizVersion: version(), CompletedAt: time.Now()})
+	}
+
+	taken := func() bool {
+		quizIDs, _ := models.GetTakenQuizIDs(student.ID)
+		return quizIDs[quiz.ID]
+	}
+
+	changes := []struct {
+		name    string
+		request func() *httptest.ResponseRecorder
+		status  int
+	}{
+		{"update question", func() *httptest.ResponseRecorder {
+			return adminRequest("PUT", fmt.Sprintf("/api/admin/questions/%d", question.ID), admin.ID, map[string]string{"text": "Favourite activity?"})
+		}, http.StatusOK},
+		{"create option", func() *httptest.ResponseRecorder {
+			return adminRequest("POST", fmt.Sprintf("/api/admin/questions/%d/options", question.ID), admin.ID, map[string]string{"text": "Running"})
+		}, http.StatusCreated},
+		{"update option", func() *httptest.ResponseRecorder {
+			return adminRequest("PUT", fmt.Sprintf("/api/admin/options/%d", question.Options[1].ID), admin.ID, map[string]string{"text": "Board games"})
+		}, http.StatusOK},
+		{"delete option", func() *httptest.ResponseRecorder {
+			return adminRequest("DELETE", fmt.Sprintf("/api/admin/options/%d", question.Options[2].ID), admin.ID, nil)
+		}, http.StatusNoContent},
+	}
+
+	for _, change := range changes {
+		before := version()
+		complete()
+		assert.True(t, taken(), change.name)
+
+		rec := change.request()
+		assert.Equal(t, change.status, rec.Code, change.name)
+		assert.Equal(t, before+1, version(), change.name)
+		assert.False(t, taken(), change.name)
+	}
+
+	// Reordering doesn't change what an answer means.
+	questions, err := models.GetQuizQuestions(quiz.ID)
+	assert.NoError(t, err)
+	options := questions[0].Options
+
+	before := version()
+	rec := adminRequest("PUT", fmt.Sprintf("/api/admin/questions/%d/options/order", question.ID), admin.ID, map[string][]uint{
+		"option_ids": {options[2].ID, options[0].ID, options[1].ID},
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, before, version())
+}
+
+func TestAdminReorderQuizContent(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	quiz, err := models.CreateQuiz(admin.ID, "Hobbies", "What you like to do")
+	assert.NoError(t, err)
+
+	first, _ := models.CreateQuestion(admin.ID, quiz.ID, "Favourite sport?", []string{"Football", "Chess", "Swimming"})
+	second, _ := models.CreateQuestion(admin.ID, quiz.ID, "Favourite food?", []string{"Pizza", "Sushi"})
+
+	rec := adminRequest("PUT", fmt.Sprintf("/api/admin/quizzes/%d/questions/order", quiz.ID), admin.ID, map[string][]uint{
+		"question_ids": {second.ID},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/quizzes/%d/questions/order", quiz.ID), admin.ID, map[string][]uint{
+		"question_ids": {second.ID, second.ID},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/quizzes/%d/questions/order", quiz.ID), admin.ID, map[string][]uint{
+		"question_ids": {second.ID, first.ID},
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/questions/%d/options/order", first.ID), admin.ID, map[string][]uint{
+		"option_ids": {first.Options[2].ID, first.Options[0].ID, second.Options[0].ID},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = adminRequest("PUT", fmt.Sprintf("/api/admin/questions/%d/options/order", first.ID), admin.ID, map[string][]uint{
+		"option_ids": {first.Options[2].ID, first.Options[0].ID, first.Options[1].ID},
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// New content goes to the end.
+	option, err := models.CreateOption(admin.ID, first.ID, "Running")
+	assert.NoError(t, err)
+
+	ordered, err := models.GetQuizWithQuestions(quiz.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, ordered.Questions, 2) && assert.Len(t, ordered.Questions[1].Options, 4) {
+		assert.Equal(t, second.ID, ordered.Questions[0].ID)
+		assert.Equal(t, first.ID, ordered.Questions[1].ID)
+
+		optionIDs := []uint{}
+		for _, o := range ordered.Questions[1].Options {
+			optionIDs = append(optionIDs, o.ID)
+		}
+		assert.Equal(t, []uint{first.Options[2].ID, first.Options[0].ID, first.Options[1].ID, option.ID}, optionIDs)
+	}
+
+	assert.Contains(t, auditActions(models.AuditTargetQuiz, quiz.ID), models.AuditQuestionsReordered)
+	assert.Contains(t, auditActions(models.AuditTargetQuestion, first.ID), models.AuditOptionsReordered)
+}
@@ -3,19 +3,26 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
+	"unifriend-api/handlers"
 	"unifriend-api/models"
 	"unifriend-api/tests/factory"
+	"unifriend-api/tests/mocks"
+	"unifriend-api/utils/token"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/xeipuuv/gojsonschema"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestLoginWithWrongCredentials(t *testing.T) {
@@ -85,7 +92,8 @@ func TestLoginDeletedUser(t *testing.T) {
 
     user := factory.UserFactory()
 	user.Status = 0
-	user.DeletedAt = time.Now()
+	deletedAt := time.Now()
+	user.DeletedAt = &deletedAt
     user.Email = "test@mail.com"
     user.Password = "Right@Password"
 
@@ -302,3 +310,352 @@ func TestLogout(t *testing.T) {
     assert.Equal(t, http.StatusNoContent, rec.Code)
 	assert.Contains(t, rec.Header().Get("Set-Cookie"), "auth_token=;")
 }
+
+func TestLogoutRevokesSession(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	authCookie := &http.Cookie{
+		Name:  "auth_token",
+		Value: factory.GetUserFactoryToken(user.ID),
+		Path:  "/",
+	}
+
+	req, _ := http.NewRequest("GET", "/api/logout", nil)
+	req.AddCookie(authCookie)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	req, _ = http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(authCookie)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Session has been revoked")
+}
+
+func TestLogoutEverywhere(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	firstToken := factory.GetUserFactoryToken(user.ID)
+	secondToken := factory.GetUserFactoryToken(user.ID)
+
+	req, _ := http.NewRequest("POST", "/api/logout/all", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: firstToken, Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	req, _ = http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: secondToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	var activeSessions int64
+	models.DB.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&activeSessions)
+	assert.Equal(t, int64(0), activeSessions)
+}
+
+func TestRefreshTokenRotatesSession(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	os.Setenv("API_SECRET", "secret")
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	_, refreshToken, err := models.CreateSession(user.ID)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/token/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: refreshToken, Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	cookies := map[string]string{}
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie.Value
+	}
+
+	assert.NotEmpty(t, cookies["auth_token"])
+	assert.NotEmpty(t, cookies["refresh_token"])
+	assert.NotEqual(t, refreshToken, cookies["refresh_token"])
+
+	req, _ = http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: cookies["auth_token"], Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req, _ = http.NewRequest("POST", "/api/token/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: refreshToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRefreshTokenRevokedSession(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	session, refreshToken, err := models.CreateSession(user.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, models.RevokeSession(session.ID, user.ID))
+
+	req, _ := http.NewRequest("POST", "/api/token/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: refreshToken, Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid refresh token")
+}
+
+func TestForgotPasswordSendsResetCode(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	var sentTo, sentBody string
+	mockEmailSender := &mocks.MockSesSender{
+		SendVerificationEmailFunc: func(recipient, subject, body string) error {
+			sentTo = recipient
+			sentBody = body
+			return nil
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.POST("/api/password/forgot", func(c *gin.Context) {
+		handlers.ForgotPassword(c, mockEmailSender)
+	})
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s"}`, user.Email))
+	req, _ := http.NewRequest("POST", "/api/password/forgot", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, user.Email, sentTo)
+	assert.True(t, models.HasValidPasswordResetCode(user.Email))
+
+	passwordReset, err := models.GetLatestPasswordResetCode(user.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, handlers.PasswordResetMessage+strconv.Itoa(passwordReset.ResetCode), sentBody)
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	mockEmailSender := &mocks.MockSesSender{
+		SendVerificationEmailFunc: func(recipient, subject, body string) error {
+			t.Fatal("no email should be sent for an unknown address")
+			return nil
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.POST("/api/password/forgot", func(c *gin.Context) {
+		handlers.ForgotPassword(c, mockEmailSender)
+	})
+
+	payload := []byte(`{"email": "nobody@mail.com"}`)
+	req, _ := http.NewRequest("POST", "/api/password/forgot", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), handlers.PasswordResetRequestedMessage)
+}
+
+func TestForgotPasswordEmailError(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	mockEmailSender := &mocks.MockSesSender{
+		SendVerificationEmailFunc: func(recipient, subject, body string) error {
+			return errors.New("AWS SES error: Email sending failed")
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.POST("/api/password/forgot", func(c *gin.Context) {
+		handlers.ForgotPassword(c, mockEmailSender)
+	})
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s"}`, user.Email))
+	req, _ := http.NewRequest("POST", "/api/password/forgot", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.False(t, models.HasValidPasswordResetCode(user.Email))
+}
+
+func TestResetPasswordSuccess(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	authToken := factory.GetUserFactoryToken(user.ID)
+	passwordReset, _ := models.SavePasswordResetCode(user.Email, 123456)
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "reset_code": 123456, "password": "New@Password1", "re_password": "New@Password1"}`, user.Email))
+	req, _ := http.NewRequest("POST", "/api/password/reset", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	loggedUser, err := models.LoginCheck(user.Email, "New@Password1")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, loggedUser.ID)
+
+	models.DB.First(passwordReset, passwordReset.ID)
+	assert.True(t, passwordReset.Used)
+
+	req, _ = http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: authToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestResetPasswordWrongCode(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	passwordReset, _ := models.SavePasswordResetCode(user.Email, 123456)
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "reset_code": 654321, "password": "New@Password1", "re_password": "New@Password1"}`, user.Email))
+	req, _ := http.NewRequest("POST", "/api/password/reset", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "code expired or is incorrect")
+
+	models.DB.First(passwordReset, passwordReset.ID)
+	assert.Equal(t, 1, passwordReset.Attempts)
+
+	_, err := models.LoginCheck(user.Email, "Old@Password1")
+	assert.NoError(t, err)
+}
+
+func TestResetPasswordExpiredCode(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	passwordReset, _ := models.SavePasswordResetCode(user.Email, 123456)
+	models.DB.Model(passwordReset).Update("expiration", time.Now().Add(-time.Minute).UTC())
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "reset_code": 123456, "password": "New@Password1", "re_password": "New@Password1"}`, user.Email))
+	req, _ := http.NewRequest("POST", "/api/password/reset", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestLoginAfterUserIsSavedAgain(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Email = "test@mail.com"
+	user.Password = "Right@Password"
+	models.DB.Create(&user)
+
+	var loaded models.User
+	models.DB.First(&loaded, user.ID)
+	loaded.Name = "new name"
+	_, err := loaded.SaveUser()
+	assert.NoError(t, err)
+
+	_, err = models.LoginCheck("test@mail.com", "Right@Password")
+	assert.NoError(t, err)
+}
+
+func TestLoginRehashesPasswordWhenCostChanges(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	os.Setenv("BCRYPT_COST", strconv.Itoa(bcrypt.MinCost))
+	defer os.Unsetenv("BCRYPT_COST")
+
+	user := factory.UserFactory()
+	user.Email = "test@mail.com"
+	user.Password = "Right@Password"
+	models.DB.Create(&user)
+
+	os.Setenv("BCRYPT_COST", strconv.Itoa(bcrypt.MinCost+1))
+
+	_, err := models.LoginCheck("test@mail.com", "Right@Password")
+	assert.NoError(t, err)
+
+	var loaded models.User
+	models.DB.First(&loaded, user.ID)
+	cost, err := bcrypt.Cost([]byte(loaded.Password))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost+1, cost)
+
+	_, err = models.LoginCheck("test@mail.com", "Right@Password")
+	assert.NoError(t, err)
+}
+
+func TestTokenLifespanFallsBackToHourSetting(t *testing.T) {
+	t.Setenv("TOKEN_MINUTE_LIFESPAN", "")
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "2")
+	assert.Equal(t, 2*time.Hour, token.TokenLifespan())
+
+	t.Setenv("TOKEN_MINUTE_LIFESPAN", "30")
+	assert.Equal(t, 30*time.Minute, token.TokenLifespan())
+
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "")
+	t.Setenv("TOKEN_MINUTE_LIFESPAN", "")
+	assert.Equal(t, 15*time.Minute, token.TokenLifespan())
+}
@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"unifriend-api/handlers"
+	"unifriend-api/models"
+	"unifriend-api/services"
+	"unifriend-api/tests/factory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func blockUser(userID uint, blockedUserID uint) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/users/%d/block", blockedUserID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(userID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func reportUser(userID uint, reportedUserID uint, input map[string]interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(input)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/users/%d/report", reportedUserID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(userID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestBlockUserRemovesConnection(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	connection := factory.ConnectionFactory()
+	models.DB.Create(&connection)
+	models.DB.Create(&models.Message{ConnectionID: connection.ID, SenderID: connection.UserAID, ReceiverID: connection.UserBID, Content: "hello"})
+
+	rec := blockUser(connection.UserAID, connection.UserBID)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var count int64
+	models.DB.Model(&models.Connection{}).Where("id = ?", connection.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	models.DB.Model(&models.Message{}).Where("connection_id = ?", connection.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	rec = blockUser(connection.UserAID, connection.UserBID)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	assert.False(t, models.ValidConnectionRequest(connection.UserAID, connection.UserBID))
+	assert.False(t, models.ValidConnectionRequest(connection.UserBID, connection.UserAID))
+}
+
+func TestBlockUserPreventsConnectionRequests(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	blockedUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&blockedUser)
+
+	blockUser(user.ID, blockedUser.ID)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/request/user/%d", user.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(blockedUser.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBlockUserInvalidTarget(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	rec := blockUser(user.ID, user.ID)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = blockUser(user.ID, user.ID+100)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestUnblockUser(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	blockedUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&blockedUser)
+
+	blockUser(user.ID, blockedUser.ID)
+
+	req, _ := http.NewRequest("GET", "/api/users/me/blocks", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), blockedUser.Name)
+
+	// Only the blocker can lift the block.
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/users/%d/block", user.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(blockedUser.ID), Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/users/%d/block", blockedUser.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.True(t, models.ValidConnectionRequest(blockedUser.ID, user.ID))
+}
+
+func TestGetConnectionsExcludesBlockedUsers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	connection := factory.ConnectionFactory()
+	models.DB.Create(&connection)
+	models.DB.Create(&models.Message{ConnectionID: connection.ID, SenderID: connection.UserAID, ReceiverID: connection.UserBID, Content: "hello"})
+	models.DB.Create(&models.Block{BlockerID: connection.UserBID, BlockedID: connection.UserAID})
+
+	connections, err := models.GetConnections(connection.UserAID)
+	assert.NoError(t, err)
+	assert.Len(t, connections, 0)
+
+	peers, err := models.GetConnectedUserIDs(connection.UserAID)
+	assert.NoError(t, err)
+	assert.Len(t, peers, 0)
+}
+
+func TestGetUserResultExcludesBlockedUsers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	userResponse := factory.UserResponseFactory()
+	userResponse.User = user
+	models.DB.Create(&userResponse)
+
+	otherUser := factory.UserFactory()
+	models.DB.Create(&otherUser)
+
+	matchingResponse := factory.UserResponseFactory()
+	matchingResponse.User = otherUser
+	matchingResponse.Question = userResponse.Question
+	matchingResponse.Option = userResponse.Option
+	models.DB.Create(&matchingResponse)
+
+	models.DB.Create(&models.Block{BlockerID: otherUser.ID, BlockedID: user.ID})
+
+	req, _ := http.NewRequest("GET", "/api/get-results/user/"+strconv.FormatUint(uint64(user.ID), 10), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.NotContains(t, rec.Body.String(), fmt.Sprintf(`"user_id":%d`, otherUser.ID))
+}
+
+func TestChatDropsEventsBetweenBlockedUsers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	handlers.SetHub(hub)
+	defer handlers.SetHub(nil)
+
+	connection := factory.ConnectionFactory()
+	models.DB.Create(&connection)
+
+	connA := DialChat(t, server, connection.UserAID)
+	defer connA.Close()
+	connB := DialChat(t, server, connection.UserBID)
+
+	ReadChatEvent(t, connA, services.EventPresence)
+
+	rec := blockUser(connection.UserAID, connection.UserBID)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	WriteChatEnvelope(connB, services.EventTypingStart, "typing-1", services.TypingPayload{ConnectionID: connection.ID})
+	_, payload := ReadChatEvent(t, connB, services.EventError)
+	assert.Equal(t, services.ErrorConnectionNotFound, payload["code"])
+
+	// Events that don't need the connection are dropped by the hub, so A
+	// neither hears B go offline nor gets B's notification; the marker sent
+	// afterwards is the next frame A reads.
+	connB.Close()
+	hub.SendFromUser(connection.UserAID, connection.UserBID, []byte(`{"type":"notification.new"}`))
+	hub.SendToUser(connection.UserAID, []byte(`{"type":"marker"}`))
+
+	frame := ReadChatFrame(t, connA)
+	assert.Equal(t, "marker", frame["type"])
+}
+
+func TestReportUserCopiesMessages(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	connection := factory.ConnectionFactory()
+	models.DB.Create(&connection)
+
+	message := models.Message{ConnectionID: connection.ID, SenderID: connection.UserBID, ReceiverID: connection.UserAID, Content: "rude"}
+	models.DB.Create(&message)
+
+	otherConnection := factory.ConnectionFactory()
+	models.DB.Create(&otherConnection)
+
+	foreignMessage := models.Message{ConnectionID: otherConnection.ID, SenderID: otherConnection.UserAID, ReceiverID: otherConnection.UserBID, Content: "hi"}
+	models.DB.Create(&foreignMessage)
+
+	rec := reportUser(connection.UserAID, connection.UserBID, map[string]interface{}{
+		"reason":      models.ReportReasonHarassment,
+		"message_ids": []uint{foreignMessage.ID},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = reportUser(connection.UserAID, connection.UserBID, map[string]interface{}{
+		"reason":      models.ReportReasonHarassment,
+		"details":     "keeps insulting me",
+		"message_ids": []uint{message.ID},
+	})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	blockUser(connection.UserAID, connection.UserBID)
+
+	var report models.Report
+	err := models.DB.Preload("Messages").Where("reporter_id = ?", connection.UserAID).First(&report).Error
+	assert.NoError(t, err)
+	assert.Equal(t, connection.UserBID, report.ReportedID)
+	assert.Equal(t, models.ReportStatusOpen, report.Status)
+	assert.Equal(t, "keeps insulting me", report.Details)
+	if assert.Len(t, report.Messages, 1) {
+		assert.Equal(t, message.ID, report.Messages[0].MessageID)
+		assert.Equal(t, "rude", report.Messages[0].Content)
+	}
+}
+
+func TestReportUserInvalidInput(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	reportedUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&reportedUser)
+
+	rec := reportUser(user.ID, reportedUser.ID, map[string]interface{}{"reason": "boring"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = reportUser(user.ID, reportedUser.ID, map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = reportUser(user.ID, user.ID, map[string]interface{}{"reason": models.ReportReasonSpam})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = reportUser(user.ID, reportedUser.ID+100, map[string]interface{}{"reason": models.ReportReasonSpam})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
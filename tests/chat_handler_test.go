@@ -0,0 +1,841 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unifriend-api/handlers"
+	"unifriend-api/middleware"
+	"unifriend-api/models"
+	"unifriend-api/services"
+	"unifriend-api/tests/factory"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+const chatTestOrigin = "http://localhost:3000"
+
+func StartChatHub() *services.Hub {
+	hub := services.NewHub()
+	go hub.Run()
+	return hub
+}
+
+// StopChatHub must run before the test database is torn down, the hub keeps
+// writing presence to it until every client is gone.
+func StopChatHub(hub *services.Hub) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	hub.Shutdown(ctx)
+}
+
+func SetupChatServer(hub *services.Hub) *httptest.Server {
+	os.Setenv("CLIENT_DOMAIN", chatTestOrigin)
+
+	chatRouter := gin.New()
+	chatRouter.Use(middleware.AuthMiddleware())
+	chatRouter.GET("/api/chat", func(c *gin.Context) {
+		handlers.HandleWebSocket(c, hub)
+	})
+
+	return httptest.NewServer(chatRouter)
+}
+
+func DialChat(t *testing.T, server *httptest.Server, userID uint) *websocket.Conn {
+	header := http.Header{}
+	header.Set("Origin", chatTestOrigin)
+	header.Set("Cookie", "auth_token="+factory.GetUserFactoryToken(userID))
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/chat"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("could not open chat socket: %v", err)
+	}
+
+	// An invalid frame is answered only once the hub has registered the
+	// client, so waiting for it makes the tests deterministic.
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	ReadChatEvent(t, conn, services.EventError)
+
+	return conn
+}
+
+func ReadChatFrame(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("could not read chat frame: %v", err)
+	}
+
+	var frame map[string]interface{}
+	json.Unmarshal(data, &frame)
+	return frame
+}
+
+// ReadChatEvent reads frames until one of the given type arrives, skipping
+// presence and other unrelated events.
+func ReadChatEvent(t *testing.T, conn *websocket.Conn, eventType string) (map[string]interface{}, map[string]interface{}) {
+	for {
+		frame := ReadChatFrame(t, conn)
+		if frame["type"] == eventType {
+			payload, _ := frame["payload"].(map[string]interface{})
+			return frame, payload
+		}
+	}
+}
+
+func WriteChatEnvelope(conn *websocket.Conn, eventType string, id string, payload interface{}) {
+	conn.WriteJSON(map[string]interface{}{
+		"type":    eventType,
+		"id":      id,
+		"payload": payload,
+	})
+}
+
+func TestChatInvalidFrameReturnsError(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	conn := DialChat(t, server, user.ID)
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "message.delete", "id": "tmp-1"}`))
+	frame, payload := ReadChatEvent(t, conn, services.EventError)
+
+	assert.Equal(t, "tmp-1", frame["id"])
+	assert.Equal(t, services.ErrorUnknownType, payload["code"])
+}
+
+func TestChatSendMessageToForeignConnection(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	intruder := factory.UserFactory()
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&intruder)
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	conn := DialChat(t, server, intruder.ID)
+	defer conn.Close()
+
+	WriteChatEnvelope(conn, services.EventMessageNew, "tmp-1", map[string]interface{}{
+		"connection_id": connection.ID,
+		"sender_id":     user1.ID,
+		"receiver_id":   user2.ID,
+		"content":       "pretending to be user1",
+	})
+	_, payload := ReadChatEvent(t, conn, services.EventError)
+
+	assert.Equal(t, services.ErrorConnectionNotFound, payload["code"])
+
+	var count int64
+	models.DB.Model(&models.Message{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestChatSendMessageDeliversToRecipient(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	senderConn := DialChat(t, server, user1.ID)
+	defer senderConn.Close()
+	recipientConn := DialChat(t, server, user2.ID)
+	defer recipientConn.Close()
+
+	WriteChatEnvelope(senderConn, services.EventMessageNew, "tmp-42", map[string]interface{}{
+		"connection_id": connection.ID,
+		"sender_id":     user2.ID,
+		"content":       "hello",
+	})
+	_, payload := ReadChatEvent(t, recipientConn, services.EventMessageNew)
+
+	assert.Equal(t, "hello", payload["content"])
+	assert.Equal(t, float64(user1.ID), payload["sender_id"])
+	assert.Equal(t, float64(user2.ID), payload["receiver_id"])
+
+	ack, ackPayload := ReadChatEvent(t, senderConn, services.EventMessageAck)
+	assert.Equal(t, "tmp-42", ack["id"])
+	assert.Equal(t, payload["id"], ackPayload["id"])
+
+	var message models.Message
+	models.DB.Where("connection_id = ?", connection.ID).First(&message)
+	assert.Equal(t, user1.ID, message.SenderID)
+	assert.Equal(t, user2.ID, message.ReceiverID)
+}
+
+func TestChatSendMessageInvalidContent(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	conn := DialChat(t, server, user1.ID)
+	defer conn.Close()
+
+	WriteChatEnvelope(conn, services.EventMessageNew, "tmp-1", map[string]interface{}{
+		"connection_id": connection.ID,
+		"content":       "   ",
+	})
+	frame, payload := ReadChatEvent(t, conn, services.EventError)
+
+	assert.Equal(t, "tmp-1", frame["id"])
+	assert.Equal(t, services.ErrorInvalidContent, payload["code"])
+}
+
+func TestChatTypingIsRelayedToOtherUser(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	senderConn := DialChat(t, server, user1.ID)
+	defer senderConn.Close()
+	recipientConn := DialChat(t, server, user2.ID)
+	defer recipientConn.Close()
+
+	WriteChatEnvelope(senderConn, services.EventTypingStart, "", map[string]interface{}{
+		"connection_id": connection.ID,
+	})
+	_, payload := ReadChatEvent(t, recipientConn, services.EventTypingStart)
+
+	assert.Equal(t, float64(connection.ID), payload["connection_id"])
+	assert.Equal(t, float64(user1.ID), payload["user_id"])
+}
+
+func TestChatPresenceIsAnnouncedToConnections(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	conn1 := DialChat(t, server, user1.ID)
+	defer conn1.Close()
+	conn2 := DialChat(t, server, user2.ID)
+
+	_, payload := ReadChatEvent(t, conn1, services.EventPresence)
+	assert.Equal(t, float64(user2.ID), payload["user_id"])
+	assert.Equal(t, true, payload["online"])
+
+	conn2.Close()
+
+	_, payload = ReadChatEvent(t, conn1, services.EventPresence)
+	assert.Equal(t, float64(user2.ID), payload["user_id"])
+	assert.Equal(t, false, payload["online"])
+	assert.NotEmpty(t, payload["last_seen_at"])
+	assert.False(t, hub.IsOnline(user2.ID))
+
+	StopChatHub(hub)
+
+	models.DB.First(&user2, user2.ID)
+	assert.NotNil(t, user2.LastSeenAt)
+}
+
+func TestGetConnectionsIncludesPresence(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	handlers.SetHub(hub)
+	defer handlers.SetHub(nil)
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	user3 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+	models.DB.Create(&user3)
+
+	lastSeen := time.Now().Add(-time.Hour)
+	models.UpdateLastSeen(user3.ID, lastSeen)
+
+	for _, peer := range []models.User{user2, user3} {
+		connection := factory.ConnectionFactory()
+		connection.UserA = user1
+		connection.UserB = peer
+		models.DB.Create(&connection)
+		models.CreateMessage(connection, peer.ID, "hi")
+	}
+
+	conn := DialChat(t, server, user2.ID)
+	defer conn.Close()
+
+	req, _ := http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID)})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string][]models.ConnectionWithUser
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Len(t, response["data"], 2)
+
+	for _, connection := range response["data"] {
+		switch connection.UserID {
+		case user2.ID:
+			assert.True(t, connection.Online)
+		case user3.ID:
+			assert.False(t, connection.Online)
+			if assert.NotNil(t, connection.LastSeenAt) {
+				assert.WithinDuration(t, lastSeen, *connection.LastSeenAt, time.Second)
+			}
+		}
+	}
+}
+
+func TestChatReadReceiptIsPersistedAndRelayed(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	message, _ := models.CreateMessage(connection, user1.ID, "hello")
+
+	senderConn := DialChat(t, server, user1.ID)
+	defer senderConn.Close()
+	readerConn := DialChat(t, server, user2.ID)
+	defer readerConn.Close()
+
+	WriteChatEnvelope(readerConn, services.EventMessageRead, "", map[string]interface{}{
+		"connection_id": connection.ID,
+		"message_id":    message.ID,
+	})
+	_, payload := ReadChatEvent(t, senderConn, services.EventMessageRead)
+
+	assert.Equal(t, float64(message.ID), payload["message_id"])
+	assert.Equal(t, float64(user2.ID), payload["user_id"])
+	assert.NotEmpty(t, payload["read_at"])
+
+	models.DB.First(&message, message.ID)
+	assert.NotNil(t, message.ReadAt)
+}
+
+func TestChatMessageFansOutToEveryDevice(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	senderPhone := DialChat(t, server, user1.ID)
+	defer senderPhone.Close()
+	senderLaptop := DialChat(t, server, user1.ID)
+	defer senderLaptop.Close()
+	recipientPhone := DialChat(t, server, user2.ID)
+	defer recipientPhone.Close()
+	recipientLaptop := DialChat(t, server, user2.ID)
+	defer recipientLaptop.Close()
+
+	WriteChatEnvelope(senderPhone, services.EventMessageNew, "tmp-1", map[string]interface{}{
+		"connection_id": connection.ID,
+		"content":       "hello everywhere",
+	})
+
+	for _, conn := range []*websocket.Conn{recipientPhone, recipientLaptop, senderLaptop} {
+		_, payload := ReadChatEvent(t, conn, services.EventMessageNew)
+		assert.Equal(t, "hello everywhere", payload["content"])
+	}
+
+	// The sending device gets the ack and not a copy of its own message.
+	frame := ReadChatFrame(t, senderPhone)
+	for frame["type"] == services.EventPresence {
+		frame = ReadChatFrame(t, senderPhone)
+	}
+	assert.Equal(t, services.EventMessageAck, frame["type"])
+}
+
+func TestChatClosingOneDeviceKeepsUserOnline(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	watcher := DialChat(t, server, user1.ID)
+	defer watcher.Close()
+	phone := DialChat(t, server, user2.ID)
+	laptop := DialChat(t, server, user2.ID)
+
+	_, payload := ReadChatEvent(t, watcher, services.EventPresence)
+	assert.Equal(t, true, payload["online"])
+
+	phone.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	WriteChatEnvelope(laptop, services.EventTypingStart, "", map[string]interface{}{
+		"connection_id": connection.ID,
+	})
+	frame := ReadChatFrame(t, watcher)
+	assert.Equal(t, services.EventTypingStart, frame["type"])
+
+	laptop.Close()
+
+	_, payload = ReadChatEvent(t, watcher, services.EventPresence)
+	assert.Equal(t, float64(user2.ID), payload["user_id"])
+	assert.Equal(t, false, payload["online"])
+}
+
+func TestChatConcurrentDevicesRegisterAndUnregister(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	recipient := DialChat(t, server, user2.ID)
+	defer recipient.Close()
+
+	devices := make(chan *websocket.Conn, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(devices); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			devices <- DialChat(t, server, user1.ID)
+		}()
+	}
+	wg.Wait()
+	close(devices)
+
+	var open []*websocket.Conn
+	for conn := range devices {
+		if len(open) < 2 {
+			open = append(open, conn)
+			continue
+		}
+		conn.Close()
+	}
+	defer open[0].Close()
+	defer open[1].Close()
+
+	WriteChatEnvelope(open[0], services.EventMessageNew, "tmp-1", map[string]interface{}{
+		"connection_id": connection.ID,
+		"content":       "still here",
+	})
+
+	_, payload := ReadChatEvent(t, recipient, services.EventMessageNew)
+	assert.Equal(t, "still here", payload["content"])
+
+	_, payload = ReadChatEvent(t, open[1], services.EventMessageNew)
+	assert.Equal(t, "still here", payload["content"])
+}
+
+func assertMessageCrossesReplicas(t *testing.T, backplaneA services.Backplane, backplaneB services.Backplane, presence services.PresenceStore) {
+	hubA, err := services.NewHubWithBackplane(backplaneA, presence)
+	assert.NoError(t, err)
+	hubB, err := services.NewHubWithBackplane(backplaneB, presence)
+	assert.NoError(t, err)
+	go hubA.Run()
+	defer StopChatHub(hubA)
+	go hubB.Run()
+	defer StopChatHub(hubB)
+
+	serverA := SetupChatServer(hubA)
+	defer serverA.Close()
+	serverB := SetupChatServer(hubB)
+	defer serverB.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	sender := DialChat(t, serverA, user1.ID)
+	defer sender.Close()
+	senderOtherDevice := DialChat(t, serverB, user1.ID)
+	defer senderOtherDevice.Close()
+	recipient := DialChat(t, serverB, user2.ID)
+	defer recipient.Close()
+
+	WriteChatEnvelope(sender, services.EventMessageNew, "tmp-1", map[string]interface{}{
+		"connection_id": connection.ID,
+		"content":       "across replicas",
+	})
+
+	_, payload := ReadChatEvent(t, recipient, services.EventMessageNew)
+	assert.Equal(t, "across replicas", payload["content"])
+
+	_, payload = ReadChatEvent(t, senderOtherDevice, services.EventMessageNew)
+	assert.Equal(t, "across replicas", payload["content"])
+
+	ack, _ := ReadChatEvent(t, sender, services.EventMessageAck)
+	assert.Equal(t, "tmp-1", ack["id"])
+}
+
+func TestChatMemoryBackplaneSharedBetweenHubs(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	backplane := services.NewMemoryBackplane()
+	defer backplane.Close()
+
+	assertMessageCrossesReplicas(t, backplane, backplane, services.NewMemoryPresence())
+}
+
+func TestChatRedisBackplaneBetweenHubs(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	redisServer := miniredis.RunT(t)
+
+	backplaneA := services.NewRedisBackplane(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "chat-test")
+	defer backplaneA.Close()
+	backplaneB := services.NewRedisBackplane(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "chat-test")
+	defer backplaneB.Close()
+
+	presence := services.NewRedisPresence(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "presence-test:")
+	defer presence.Close()
+
+	assertMessageCrossesReplicas(t, backplaneA, backplaneB, presence)
+}
+
+func TestChatPresenceIsSharedBetweenReplicas(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	redisServer := miniredis.RunT(t)
+
+	backplaneA := services.NewRedisBackplane(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "chat-test")
+	defer backplaneA.Close()
+	backplaneB := services.NewRedisBackplane(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "chat-test")
+	defer backplaneB.Close()
+	presenceA := services.NewRedisPresence(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "presence-test:")
+	defer presenceA.Close()
+	presenceB := services.NewRedisPresence(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "presence-test:")
+	defer presenceB.Close()
+
+	hubA, err := services.NewHubWithBackplane(backplaneA, presenceA)
+	assert.NoError(t, err)
+	hubB, err := services.NewHubWithBackplane(backplaneB, presenceB)
+	assert.NoError(t, err)
+	go hubA.Run()
+	defer StopChatHub(hubA)
+	go hubB.Run()
+	defer StopChatHub(hubB)
+
+	serverA := SetupChatServer(hubA)
+	defer serverA.Close()
+	serverB := SetupChatServer(hubB)
+	defer serverB.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	watcher := DialChat(t, serverA, user1.ID)
+	defer watcher.Close()
+	phone := DialChat(t, serverA, user2.ID)
+	laptop := DialChat(t, serverB, user2.ID)
+	defer laptop.Close()
+
+	_, payload := ReadChatEvent(t, watcher, services.EventPresence)
+	assert.Equal(t, true, payload["online"])
+	assert.True(t, hubA.IsOnline(user2.ID))
+
+	phone.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	// The laptop on the other replica keeps the user online, so the watcher
+	// gets the typing event and no offline presence before it.
+	assert.True(t, hubA.IsOnline(user2.ID))
+	WriteChatEnvelope(laptop, services.EventTypingStart, "", map[string]interface{}{
+		"connection_id": connection.ID,
+	})
+	frame := ReadChatFrame(t, watcher)
+	assert.Equal(t, services.EventTypingStart, frame["type"])
+
+	laptop.Close()
+
+	_, payload = ReadChatEvent(t, watcher, services.EventPresence)
+	assert.Equal(t, float64(user2.ID), payload["user_id"])
+	assert.Equal(t, false, payload["online"])
+	assert.False(t, hubA.IsOnline(user2.ID))
+	assert.False(t, hubB.IsOnline(user2.ID))
+}
+
+func TestMemoryBackplaneWaitsForSlowSubscriber(t *testing.T) {
+	backplane := services.NewMemoryBackplane()
+	defer backplane.Close()
+
+	deliveries, err := backplane.Subscribe()
+	assert.NoError(t, err)
+
+	const total = 2000
+	received := make(chan int)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+
+		count := 0
+		for range deliveries {
+			count++
+			if count == total {
+				break
+			}
+		}
+		received <- count
+	}()
+
+	for i := 0; i < total; i++ {
+		assert.NoError(t, backplane.Publish(&services.Delivery{UserID: uint(i + 1)}))
+	}
+
+	select {
+	case count := <-received:
+		assert.Equal(t, total, count)
+	case <-time.After(2 * time.Second):
+		t.Fatal("deliveries were dropped")
+	}
+}
+
+func TestMemoryBackplaneSlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	backplane := services.NewMemoryBackplane()
+
+	_, err := backplane.Subscribe()
+	assert.NoError(t, err)
+
+	// Nobody reads the subscription, so once its buffer is full the next
+	// publish waits on it.
+	for i := 0; i < 1024; i++ {
+		assert.NoError(t, backplane.Publish(&services.Delivery{UserID: uint(i + 1)}))
+	}
+
+	published := make(chan struct{})
+	go func() {
+		backplane.Publish(&services.Delivery{UserID: 1})
+		close(published)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	_, err = backplane.Subscribe()
+	assert.NoError(t, err)
+	assert.NoError(t, backplane.Close())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	select {
+	case <-published:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("publish kept waiting after the backplane was closed")
+	}
+}
+
+func TestRedisBackplanePublishAndSubscribe(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+
+	backplane := services.NewRedisBackplane(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}), "chat-test")
+	defer backplane.Close()
+
+	deliveries, err := backplane.Subscribe()
+	assert.NoError(t, err)
+
+	err = backplane.Publish(&services.Delivery{UserID: 7, Data: []byte(`{"type":"presence"}`), SkipClient: "abc"})
+	assert.NoError(t, err)
+
+	select {
+	case delivery := <-deliveries:
+		assert.Equal(t, uint(7), delivery.UserID)
+		assert.JSONEq(t, `{"type":"presence"}`, string(delivery.Data))
+		assert.Equal(t, "abc", delivery.SkipClient)
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery was not received")
+	}
+}
+
+func TestChatHubShutdownClosesClients(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	conn1 := DialChat(t, server, user1.ID)
+	defer conn1.Close()
+	conn2 := DialChat(t, server, user1.ID)
+	defer conn2.Close()
+	conn3 := DialChat(t, server, user2.ID)
+	defer conn3.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- hub.Shutdown(ctx) }()
+
+	for _, conn := range []*websocket.Conn{conn1, conn2, conn3} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error: %v", err)
+	}
+
+	assert.NoError(t, <-done)
+
+	header := http.Header{}
+	header.Set("Origin", chatTestOrigin)
+	header.Set("Cookie", "auth_token="+factory.GetUserFactoryToken(user1.ID))
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/chat"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error: %v", err)
+}
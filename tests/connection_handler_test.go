@@ -300,3 +300,37 @@ func TestDeleteConnection(t *testing.T) {
     assert.Error(t, err)
     assert.Zero(t, newConnection.ID)
 }
+
+func TestGetConnectionsIncludesUnreadCount(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	first, _ := models.CreateMessage(connection, user1.ID, "first")
+	models.CreateMessage(connection, user1.ID, "second")
+	models.CreateMessage(connection, user2.ID, "reply")
+	models.CreateMessage(connection, user1.ID, "third")
+	models.MarkMessagesRead(connection.ID, user2.ID, first.ID)
+
+	req, _ := http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user2.ID)})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string][]models.ConnectionWithUser
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Len(t, response["data"], 1)
+	assert.Equal(t, int64(2), response["data"][0].UnreadCount)
+	assert.Equal(t, "third", response["data"][0].Content)
+}
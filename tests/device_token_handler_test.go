@@ -0,0 +1,416 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unifriend-api/handlers"
+	"unifriend-api/models"
+	"unifriend-api/services"
+	"unifriend-api/tests/factory"
+	"unifriend-api/tests/mocks"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSubscriptionKeys returns keys a browser would hand out for a push
+// subscription.
+func newSubscriptionKeys(t *testing.T) (string, string) {
+	privateKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate subscription key: %v", err)
+	}
+
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	return base64.RawURLEncoding.EncodeToString(privateKey.PublicKey().Bytes()), base64.RawURLEncoding.EncodeToString(auth)
+}
+
+func registerDevice(userID uint, endpoint string) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"endpoint": endpoint,
+		"keys":     map[string]string{"p256dh": "p256dh-key", "auth": "auth-key"},
+	})
+
+	req, _ := http.NewRequest("POST", "/api/users/me/devices", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(userID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRegisterDeviceToken(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	rec := registerDevice(user.ID, "https://fcm.googleapis.com/send/abc")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "auth-key")
+
+	rec = registerDevice(user.ID, "https://fcm.googleapis.com/send/abc")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	deviceTokens, _ := models.GetUserDeviceTokens(user.ID)
+	assert.Len(t, deviceTokens, 1)
+	assert.Equal(t, "p256dh-key", deviceTokens[0].P256dh)
+}
+
+func TestRegisterDeviceTokenMovesEndpointToNewUser(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	registerDevice(user1.ID, "https://fcm.googleapis.com/send/shared")
+	rec := registerDevice(user2.ID, "https://fcm.googleapis.com/send/shared")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	deviceTokens, _ := models.GetUserDeviceTokens(user1.ID)
+	assert.Len(t, deviceTokens, 0)
+	deviceTokens, _ = models.GetUserDeviceTokens(user2.ID)
+	assert.Len(t, deviceTokens, 1)
+}
+
+func TestLogoutRemovesDeviceTokensOfSession(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	authToken := factory.GetUserFactoryToken(user.ID)
+	registerDevice(user.ID, "https://fcm.googleapis.com/send/other")
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"endpoint": "https://fcm.googleapis.com/send/current",
+		"keys":     map[string]string{"p256dh": "p256dh-key", "auth": "auth-key"},
+	})
+	req, _ := http.NewRequest("POST", "/api/users/me/devices", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: authToken, Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	req, _ = http.NewRequest("GET", "/api/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: authToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	deviceTokens, _ := models.GetUserDeviceTokens(user.ID)
+	if assert.Len(t, deviceTokens, 1) {
+		assert.Equal(t, "https://fcm.googleapis.com/send/other", deviceTokens[0].Endpoint)
+	}
+
+	assert.NoError(t, models.RevokeUserSessions(user.ID))
+
+	deviceTokens, _ = models.GetUserDeviceTokens(user.ID)
+	assert.Len(t, deviceTokens, 0)
+}
+
+func TestRegisterDeviceTokenInvalidEndpoint(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	rec := registerDevice(user.ID, "not a url")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRegisterDeviceTokenRejectsEndpointsOutsidePushServices(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	for _, endpoint := range []string{
+		"http://127.0.0.1/send/abc",
+		"https://127.0.0.1/send/abc",
+		"https://169.254.169.254/latest/meta-data",
+		"http://fcm.googleapis.com/fcm/send/abc",
+		"https://internal.example.com/send/abc",
+		"https://fcm.googleapis.com.example.com/send/abc",
+	} {
+		rec := registerDevice(user.ID, endpoint)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, endpoint)
+	}
+
+	deviceTokens, _ := models.GetUserDeviceTokens(user.ID)
+	assert.Len(t, deviceTokens, 0)
+}
+
+func TestGetAndDeleteDeviceTokens(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	session, _, _ := models.CreateSession(user.ID)
+	deviceToken, _ := models.SaveDeviceToken(user.ID, session.ID, "https://fcm.googleapis.com/send/1", "key", "auth", "")
+
+	req, _ := http.NewRequest("GET", "/api/users/me/devices", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "https://fcm.googleapis.com/send/1")
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/users/me/devices/%d", deviceToken.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(otherUser.ID), Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/users/me/devices/%d", deviceToken.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	deviceTokens, _ := models.GetUserDeviceTokens(user.ID)
+	assert.Len(t, deviceTokens, 0)
+}
+
+func TestPushSentForMessageToOfflineUser(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	notifier := &mocks.FakeNotifier{}
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	hub.SetNotifier(notifier)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	handlers.SetHub(hub)
+	defer handlers.SetHub(nil)
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	user3 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+	models.DB.Create(&user3)
+
+	offlineConnection := factory.ConnectionFactory()
+	offlineConnection.UserA = user1
+	offlineConnection.UserB = user2
+	models.DB.Create(&offlineConnection)
+
+	onlineConnection := factory.ConnectionFactory()
+	onlineConnection.UserA = user1
+	onlineConnection.UserB = user3
+	models.DB.Create(&onlineConnection)
+
+	conn := DialChat(t, server, user3.ID)
+	defer conn.Close()
+
+	for _, connection := range []models.Connection{offlineConnection, onlineConnection} {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d", connection.ID), bytes.NewBuffer([]byte(`{"content": "hello"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID), Path: "/"})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	sent := notifier.Sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, user2.ID, sent[0].UserID)
+		assert.Equal(t, services.PushMessageNew, sent[0].Notification.Type)
+		assert.Equal(t, user1.Name, sent[0].Notification.Title)
+		assert.Equal(t, "hello", sent[0].Notification.Body)
+	}
+}
+
+func TestPushNotSentToUserOnlineOnAnotherReplica(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	backplane := services.NewMemoryBackplane()
+	defer backplane.Close()
+	presence := services.NewMemoryPresence()
+
+	notifier := &mocks.FakeNotifier{}
+	hubA, err := services.NewHubWithBackplane(backplane, presence)
+	assert.NoError(t, err)
+	hubA.SetNotifier(notifier)
+	hubB, err := services.NewHubWithBackplane(backplane, presence)
+	assert.NoError(t, err)
+	go hubA.Run()
+	defer StopChatHub(hubA)
+	go hubB.Run()
+	defer StopChatHub(hubB)
+
+	serverB := SetupChatServer(hubB)
+	defer serverB.Close()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	conn := DialChat(t, serverB, user2.ID)
+
+	message, _ := models.CreateMessage(connection, user1.ID, "hello")
+	hubA.Broadcast(&message)
+
+	_, payload := ReadChatEvent(t, conn, services.EventMessageNew)
+	assert.Equal(t, "hello", payload["content"])
+	assert.Empty(t, notifier.Sent())
+
+	conn.Close()
+	assert.Eventually(t, func() bool { return !hubA.IsOnline(user2.ID) }, 2*time.Second, 10*time.Millisecond)
+
+	message, _ = models.CreateMessage(connection, user1.ID, "are you there?")
+	hubA.Broadcast(&message)
+
+	sent := notifier.Sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, user2.ID, sent[0].UserID)
+		assert.Equal(t, "are you there?", sent[0].Notification.Body)
+	}
+}
+
+func TestPushSentForConnectionRequestEvents(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	notifier := &mocks.FakeNotifier{}
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	hub.SetNotifier(notifier)
+
+	handlers.SetHub(hub)
+	defer handlers.SetHub(nil)
+
+	requestingUser := factory.UserFactory()
+	requestedUser := factory.UserFactory()
+	models.DB.Create(&requestingUser)
+	models.DB.Create(&requestedUser)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/request/user/%d", requestedUser.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(requestingUser.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var connectionRequest models.ConnectionRequest
+	models.DB.Where("requesting_user_id = ?", requestingUser.ID).First(&connectionRequest)
+
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/connections/requests/%d/accept", connectionRequest.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(requestedUser.ID), Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	sent := notifier.Sent()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, requestedUser.ID, sent[0].UserID)
+		assert.Equal(t, services.PushConnectionRequestReceived, sent[0].Notification.Type)
+		assert.Contains(t, sent[0].Notification.Body, requestingUser.Name)
+
+		assert.Equal(t, requestingUser.ID, sent[1].UserID)
+		assert.Equal(t, services.PushConnectionRequestAccepted, sent[1].Notification.Type)
+		assert.Contains(t, sent[1].Notification.Body, requestedUser.Name)
+	}
+}
+
+func TestWebPushNotifierRemovesExpiredDevices(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	privateKey, publicKey, err := webpush.GenerateVAPIDKeys()
+	assert.NoError(t, err)
+
+	os.Setenv("VAPID_PUBLIC_KEY", publicKey)
+	os.Setenv("VAPID_PRIVATE_KEY", privateKey)
+	os.Setenv("VAPID_SUBJECT", "mailto:support@unifriend.com")
+	defer os.Unsetenv("VAPID_PUBLIC_KEY")
+	defer os.Unsetenv("VAPID_PRIVATE_KEY")
+	defer os.Unsetenv("VAPID_SUBJECT")
+
+	var received atomic.Int32
+	pushService := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		assert.Contains(t, r.Header.Get("Authorization"), "vapid")
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer pushService.Close()
+
+	// The test push service listens on loopback, which the default client
+	// refuses to reach.
+	os.Setenv("PUSH_ALLOWED_HOSTS", "127.0.0.1")
+	defer os.Unsetenv("PUSH_ALLOWED_HOSTS")
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	session, _, _ := models.CreateSession(user.ID)
+	revokedSession, _, _ := models.CreateSession(user.ID)
+
+	p256dh, auth := newSubscriptionKeys(t)
+	models.SaveDeviceToken(user.ID, session.ID, pushService.URL+"/active", p256dh, auth, "")
+	models.SaveDeviceToken(user.ID, session.ID, pushService.URL+"/gone", p256dh, auth, "")
+	models.SaveDeviceToken(user.ID, revokedSession.ID, pushService.URL+"/revoked", p256dh, auth, "")
+	models.DB.Model(revokedSession).Update("revoked_at", time.Now().UTC())
+
+	notifier, err := services.NewWebPushNotifierWithClient(pushService.Client())
+	assert.NoError(t, err)
+
+	err = notifier.Notify(user.ID, services.PushNotification{Type: services.PushMessageNew, Title: "title", Body: "body"})
+	assert.NoError(t, err)
+	assert.NoError(t, notifier.Shutdown(context.Background()))
+
+	assert.Equal(t, int32(2), received.Load())
+
+	err = notifier.Notify(user.ID, services.PushNotification{Type: services.PushMessageNew, Title: "title", Body: "body"})
+	assert.Error(t, err)
+
+	deviceTokens, _ := models.GetPushDeviceTokens(user.ID)
+	if assert.Len(t, deviceTokens, 1) {
+		assert.Equal(t, pushService.URL+"/active", deviceTokens[0].Endpoint)
+	}
+}
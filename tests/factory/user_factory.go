@@ -3,7 +3,6 @@ package factory
 import (
 	"fmt"
 	"os"
-	"time"
 	"unifriend-api/models"
 	"unifriend-api/utils/token"
 
@@ -22,16 +21,29 @@ func UserFactory() models.User {
 		Major:           	MajorFactory(),
 		Images:            []models.UsersImages{UsersImagesFactory()},
 		Status: 1,
-		DeletedAt: time.Time{},
+		DeletedAt: nil,
 	}
 
 	return user
 }
 
+func AdminUserFactory() models.User {
+	user := UserFactory()
+	user.IsAdmin = true
+
+	return user
+}
+
 func GetUserFactoryToken(user_id uint) string {
-	os.Setenv("TOKEN_HOUR_LIFESPAN", "1")
+	os.Setenv("TOKEN_MINUTE_LIFESPAN", "15")
 	os.Setenv("API_SECRET", "secret")
-	token, error := token.GenerateToken(uint(user_id))
+
+	session, _, err := models.CreateSession(user_id)
+	if err != nil {
+		return ""
+	}
+
+	token, error := token.GenerateToken(uint(user_id), session.ID)
 
 	if error == nil {
 		return token
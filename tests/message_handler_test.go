@@ -0,0 +1,465 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unifriend-api/handlers"
+	"unifriend-api/models"
+	"unifriend-api/tests/factory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendMessageSuccess(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	payload := []byte(`{"content": "  hello there  "}`)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user2.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var response map[string]models.Message
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Equal(t, user2.ID, response["data"].SenderID)
+	assert.Equal(t, user1.ID, response["data"].ReceiverID)
+
+	var message models.Message
+	models.DB.Where("connection_id = ?", connection.ID).First(&message)
+	assert.Equal(t, "hello there", message.Content)
+	assert.Equal(t, user2.ID, message.SenderID)
+	assert.Equal(t, user1.ID, message.ReceiverID)
+}
+
+func TestSendMessageTooLong(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	payload, _ := json.Marshal(map[string]string{"content": strings.Repeat("a", models.MaxMessageContentLength+1)})
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), models.ErrMessageTooLong.Error())
+}
+
+func TestSendMessageBlankContent(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	payload := []byte(`{"content": "   "}`)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSendMessageNotPartOfConnection(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	outsider := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+	models.DB.Create(&outsider)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	payload := []byte(`{"content": "hello"}`)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(outsider.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	var count int64
+	models.DB.Model(&models.Message{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestSendMessageConnectionNotFound(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	payload := []byte(`{"content": "hello"}`)
+	req, _ := http.NewRequest("POST", "/api/connections/messages/999", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestMarkMessagesReadSuccess(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	first, _ := models.CreateMessage(connection, user1.ID, "first")
+	second, _ := models.CreateMessage(connection, user1.ID, "second")
+	reply, _ := models.CreateMessage(connection, user2.ID, "reply")
+	third, _ := models.CreateMessage(connection, user1.ID, "third")
+
+	payload := []byte(fmt.Sprintf(`{"message_id": %d}`, second.ID))
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d/read", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user2.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	for _, id := range []uint{first.ID, second.ID} {
+		var message models.Message
+		models.DB.First(&message, id)
+		assert.NotNil(t, message.ReadAt)
+	}
+
+	for _, id := range []uint{reply.ID, third.ID} {
+		var message models.Message
+		models.DB.First(&message, id)
+		assert.Nil(t, message.ReadAt)
+	}
+}
+
+func TestMarkMessagesReadFromSuspendedUser(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	message, _ := models.CreateMessage(connection, user1.ID, "hello")
+	models.DB.Model(&user1).Update("status", 0)
+
+	payload := []byte(fmt.Sprintf(`{"message_id": %d}`, message.ID))
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d/read", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user2.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	models.DB.First(&message, message.ID)
+	assert.Nil(t, message.ReadAt)
+}
+
+func TestMarkMessagesReadNotPartOfConnection(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	intruder := factory.UserFactory()
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&intruder)
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	message, _ := models.CreateMessage(connection, user1.ID, "hello")
+
+	payload := []byte(fmt.Sprintf(`{"message_id": %d}`, message.ID))
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d/read", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(intruder.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	models.DB.First(&message, message.ID)
+	assert.Nil(t, message.ReadAt)
+}
+
+func TestMarkMessagesReadMessageFromOtherConnection(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	user3 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+	models.DB.Create(&user3)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	otherConnection := factory.ConnectionFactory()
+	otherConnection.UserA = user1
+	otherConnection.UserB = user3
+	models.DB.Create(&otherConnection)
+
+	message, _ := models.CreateMessage(otherConnection, user3.ID, "hello")
+
+	payload := []byte(fmt.Sprintf(`{"message_id": %d}`, message.ID))
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/messages/%d/read", connection.ID), bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetUnreadCount(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	user3 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+	models.DB.Create(&user3)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	otherConnection := factory.ConnectionFactory()
+	otherConnection.UserA = user1
+	otherConnection.UserB = user3
+	models.DB.Create(&otherConnection)
+
+	read, _ := models.CreateMessage(connection, user2.ID, "read")
+	models.MarkMessagesRead(connection.ID, user1.ID, read.ID)
+	models.CreateMessage(connection, user2.ID, "unread")
+	models.CreateMessage(otherConnection, user3.ID, "unread too")
+	models.CreateMessage(connection, user1.ID, "sent by me")
+
+	req, _ := http.NewRequest("GET", "/api/connections/unread", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": {"unread_count": 2}}`, rec.Body.String())
+}
+
+func TestGetMessagesPaginatesWithCursors(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	var ids []uint
+	for i := 0; i < 5; i++ {
+		message, _ := models.CreateMessage(connection, user1.ID, fmt.Sprintf("message %d", i))
+		ids = append(ids, message.ID)
+	}
+
+	getPage := func(query string) handlers.MessageWithUser {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/connections/messages/%d?%s", connection.ID, query), nil)
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user2.ID), Path: "/"})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]handlers.MessageWithUser
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return response["data"]
+	}
+
+	latest := getPage("limit=2")
+	assert.True(t, latest.HasMore)
+	assert.Equal(t, ids[3], latest.Messages[0].ID)
+	assert.Equal(t, ids[4], latest.Messages[1].ID)
+	assert.Equal(t, user1.ID, latest.User.UserID)
+
+	older := getPage(fmt.Sprintf("limit=2&before=%d", ids[3]))
+	assert.True(t, older.HasMore)
+	assert.Equal(t, ids[1], older.Messages[0].ID)
+	assert.Equal(t, ids[2], older.Messages[1].ID)
+
+	oldest := getPage(fmt.Sprintf("limit=2&before=%d", ids[1]))
+	assert.False(t, oldest.HasMore)
+	assert.Len(t, oldest.Messages, 1)
+	assert.Equal(t, ids[0], oldest.Messages[0].ID)
+
+	newer := getPage(fmt.Sprintf("limit=3&after=%d", ids[1]))
+	assert.False(t, newer.HasMore)
+	assert.Equal(t, []uint{ids[2], ids[3], ids[4]}, []uint{newer.Messages[0].ID, newer.Messages[1].ID, newer.Messages[2].ID})
+}
+
+func TestGetMessagesRejectsBothCursors(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/connections/messages/%d?before=10&after=2", connection.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetMessagesRejectsLimitAboveMaximum(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/connections/messages/%d?limit=101", connection.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user1.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetMessagesCapsLimitAtMaximum(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	for i := 0; i < models.MaxMessagePageSize+1; i++ {
+		models.CreateMessage(connection, user1.ID, fmt.Sprintf("message %d", i))
+	}
+
+	messages, hasMore, err := models.GetMessages(connection.ID, models.MessageCursor{Limit: models.MaxMessagePageSize + 50})
+
+	assert.NoError(t, err)
+	assert.True(t, hasMore)
+	assert.Len(t, messages, models.MaxMessagePageSize)
+}
+
+func TestMarkMessagesReadStoresUTC(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user1 := factory.UserFactory()
+	user2 := factory.UserFactory()
+	models.DB.Create(&user1)
+	models.DB.Create(&user2)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user1
+	connection.UserB = user2
+	models.DB.Create(&connection)
+
+	message, _ := models.CreateMessage(connection, user1.ID, "hello")
+
+	read, readAt, err := models.MarkMessagesRead(connection.ID, user2.ID, message.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), read)
+	assert.Equal(t, time.UTC, readAt.Location())
+}
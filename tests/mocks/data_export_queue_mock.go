@@ -0,0 +1,13 @@
+package mocks
+
+import (
+	"unifriend-api/models"
+)
+
+type MockDataExportQueue struct {
+	EnqueueFunc func(dataExport *models.DataExport) error
+}
+
+func (m *MockDataExportQueue) Enqueue(dataExport *models.DataExport) error {
+	return m.EnqueueFunc(dataExport)
+}
@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"sync"
+	"unifriend-api/services"
+)
+
+type SentNotification struct {
+	UserID       uint
+	Notification services.PushNotification
+}
+
+// FakeNotifier records every notification instead of sending it.
+type FakeNotifier struct {
+	mu   sync.Mutex
+	sent []SentNotification
+}
+
+func (f *FakeNotifier) Notify(userID uint, notification services.PushNotification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, SentNotification{UserID: userID, Notification: notification})
+	return nil
+}
+
+func (f *FakeNotifier) Sent() []SentNotification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]SentNotification(nil), f.sent...)
+}
@@ -0,0 +1,248 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unifriend-api/handlers"
+	"unifriend-api/models"
+	"unifriend-api/services"
+	"unifriend-api/tests/factory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type notificationsResponse struct {
+	Data        []models.NotificationWithActor `json:"data"`
+	HasMore     bool                           `json:"has_more"`
+	UnreadCount int64                          `json:"unread_count"`
+}
+
+func getNotifications(t *testing.T, userID uint, query string) notificationsResponse {
+	req, _ := http.NewRequest("GET", "/api/notifications"+query, nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(userID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response notificationsResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+
+	return response
+}
+
+func TestConnectionRequestEventsCreateNotifications(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	requestingUser := factory.UserFactory()
+	requestedUser := factory.UserFactory()
+	models.DB.Create(&requestingUser)
+	models.DB.Create(&requestedUser)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/request/user/%d", requestedUser.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(requestingUser.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	response := getNotifications(t, requestedUser.ID, "")
+	if assert.Len(t, response.Data, 1) {
+		assert.Equal(t, models.NotificationConnectionRequestReceived, response.Data[0].Type)
+		assert.Equal(t, requestingUser.ID, response.Data[0].ActorID)
+		assert.Equal(t, requestingUser.Name, response.Data[0].ActorName)
+		assert.Equal(t, models.NotificationTargetConnectionRequest, response.Data[0].TargetType)
+		assert.Nil(t, response.Data[0].ReadAt)
+	}
+	assert.Equal(t, int64(1), response.UnreadCount)
+
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/connections/requests/%d/accept", response.Data[0].TargetID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(requestedUser.ID), Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var connection models.Connection
+	models.DB.Where("user_a = ? AND user_b = ?", requestingUser.ID, requestedUser.ID).First(&connection)
+
+	response = getNotifications(t, requestingUser.ID, "")
+	if assert.Len(t, response.Data, 1) {
+		assert.Equal(t, models.NotificationConnectionRequestAccepted, response.Data[0].Type)
+		assert.Equal(t, requestedUser.ID, response.Data[0].ActorID)
+		assert.Equal(t, models.NotificationTargetConnection, response.Data[0].TargetType)
+		assert.Equal(t, connection.ID, response.Data[0].TargetID)
+	}
+}
+
+func TestGetNotificationsPagesNewestFirst(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	actor := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&actor)
+
+	var ids []uint
+	for i := 1; i <= 3; i++ {
+		notification, err := models.CreateNotification(user.ID, models.NotificationConnectionRequestReceived, actor.ID, models.NotificationTargetConnectionRequest, uint(i))
+		assert.NoError(t, err)
+		ids = append(ids, notification.ID)
+	}
+
+	models.CreateNotification(actor.ID, models.NotificationConnectionRequestReceived, user.ID, models.NotificationTargetConnectionRequest, 4)
+
+	response := getNotifications(t, user.ID, "?limit=2")
+	if assert.Len(t, response.Data, 2) {
+		assert.Equal(t, ids[2], response.Data[0].ID)
+		assert.Equal(t, ids[1], response.Data[1].ID)
+	}
+	assert.True(t, response.HasMore)
+	assert.Equal(t, int64(3), response.UnreadCount)
+
+	response = getNotifications(t, user.ID, fmt.Sprintf("?limit=2&before=%d", ids[1]))
+	if assert.Len(t, response.Data, 1) {
+		assert.Equal(t, ids[0], response.Data[0].ID)
+	}
+	assert.False(t, response.HasMore)
+}
+
+func TestGetNotificationsHidesBlockedUsers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	blocked := factory.UserFactory()
+	blocker := factory.UserFactory()
+	friend := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&blocked)
+	models.DB.Create(&blocker)
+	models.DB.Create(&friend)
+
+	for i, actor := range []models.User{blocked, blocker, friend} {
+		_, err := models.CreateNotification(user.ID, models.NotificationConnectionRequestReceived, actor.ID, models.NotificationTargetConnectionRequest, uint(i+1))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, models.BlockUser(user.ID, blocked.ID))
+	assert.NoError(t, models.BlockUser(blocker.ID, user.ID))
+
+	response := getNotifications(t, user.ID, "")
+	if assert.Len(t, response.Data, 1) {
+		assert.Equal(t, friend.ID, response.Data[0].ActorID)
+	}
+	assert.Equal(t, int64(1), response.UnreadCount)
+}
+
+func TestGetNotificationsInvalidLimit(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	req, _ := http.NewRequest("GET", "/api/notifications?limit=500", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestMarkNotificationRead(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	actor := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&actor)
+
+	first, _ := models.CreateNotification(user.ID, models.NotificationConnectionRequestReceived, actor.ID, models.NotificationTargetConnectionRequest, 1)
+	models.CreateNotification(user.ID, models.NotificationConnectionRequestReceived, actor.ID, models.NotificationTargetConnectionRequest, 2)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/notifications/%d/read", first.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(actor.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/notifications/%d/read", first.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	response := getNotifications(t, user.ID, "?unread=true")
+	if assert.Len(t, response.Data, 1) {
+		assert.NotEqual(t, first.ID, response.Data[0].ID)
+	}
+	assert.Equal(t, int64(1), response.UnreadCount)
+}
+
+func TestMarkAllNotificationsRead(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	actor := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&actor)
+
+	models.CreateNotification(user.ID, models.NotificationConnectionRequestReceived, actor.ID, models.NotificationTargetConnectionRequest, 1)
+	models.CreateNotification(user.ID, models.NotificationConnectionRequestReceived, actor.ID, models.NotificationTargetConnectionRequest, 2)
+	models.CreateNotification(actor.ID, models.NotificationConnectionRequestReceived, user.ID, models.NotificationTargetConnectionRequest, 3)
+
+	req, _ := http.NewRequest("POST", "/api/notifications/read", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": {"updated": 2}}`, rec.Body.String())
+
+	assert.Equal(t, int64(0), getNotifications(t, user.ID, "").UnreadCount)
+	assert.Equal(t, int64(1), getNotifications(t, actor.ID, "").UnreadCount)
+}
+
+func TestChatDeliversNotifications(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	hub := StartChatHub()
+	defer StopChatHub(hub)
+	server := SetupChatServer(hub)
+	defer server.Close()
+
+	handlers.SetHub(hub)
+	defer handlers.SetHub(nil)
+
+	requestingUser := factory.UserFactory()
+	requestedUser := factory.UserFactory()
+	models.DB.Create(&requestingUser)
+	models.DB.Create(&requestedUser)
+
+	conn := DialChat(t, server, requestedUser.ID)
+	defer conn.Close()
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/connections/request/user/%d", requestedUser.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(requestingUser.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	_, payload := ReadChatEvent(t, conn, services.EventNotificationNew)
+	assert.Equal(t, models.NotificationConnectionRequestReceived, payload["type"])
+	assert.Equal(t, float64(requestingUser.ID), payload["actor_id"])
+	assert.Equal(t, requestingUser.Name, payload["actor_name"])
+	assert.Nil(t, payload["read_at"])
+}
@@ -3,11 +3,14 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 	"unifriend-api/models"
 	"unifriend-api/tests/factory"
 
@@ -164,3 +167,358 @@ func TestSaveAnswers(t *testing.T) {
 	}
 
 }
+
+func quizRequest(method string, path string, userID uint, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(userID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func createQuizWithQuestions(count int) (models.QuizTable, []models.QuestionTable) {
+	quiz := factory.QuizTableFactory()
+	models.DB.Create(&quiz)
+
+	questions := make([]models.QuestionTable, 0, count)
+	for i := 0; i < count; i++ {
+		question := factory.QuestionTableFactory()
+		question.Quiz = quiz
+		models.DB.Create(&question)
+		questions = append(questions, question)
+	}
+
+	return quiz, questions
+}
+
+func quizAnswers(quiz models.QuizTable, questions []models.QuestionTable, option int) map[string]interface{} {
+	answers := make([]map[string]uint, 0, len(questions))
+	for _, question := range questions {
+		answers = append(answers, map[string]uint{"question_id": question.ID, "option_id": question.Options[option].ID})
+	}
+
+	return map[string]interface{}{"quiz_id": quiz.ID, "answers": answers}
+}
+
+func TestGetQuizzes(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	hobbies, hobbyQuestions := createQuizWithQuestions(2)
+	music, _ := createQuizWithQuestions(3)
+	draft, _ := createQuizWithQuestions(1)
+	models.DB.Model(&draft).Update("status", models.QuizStatusDraft)
+
+	rec := quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(hobbies, hobbyQuestions, 0))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = quizRequest("GET", "/api/quizzes", user.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Data []models.QuizWithProgress `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+
+	if assert.Len(t, response.Data, 2) {
+		assert.Equal(t, hobbies.ID, response.Data[0].ID)
+		assert.Equal(t, 2, response.Data[0].QuestionCount)
+		assert.True(t, response.Data[0].Completed)
+		assert.NotNil(t, response.Data[0].CompletedAt)
+
+		assert.Equal(t, music.ID, response.Data[1].ID)
+		assert.Equal(t, 3, response.Data[1].QuestionCount)
+		assert.False(t, response.Data[1].Completed)
+		assert.Nil(t, response.Data[1].CompletedAt)
+	}
+}
+
+func TestTakeQuizzesSeparately(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	hobbies, hobbyQuestions := createQuizWithQuestions(2)
+	music, musicQuestions := createQuizWithQuestions(2)
+
+	rec := quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/questions", hobbies.ID), user.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if assert.Len(t, response.Data, 2) {
+		assert.Equal(t, float64(hobbyQuestions[0].ID), response.Data[0]["id"])
+		assert.Equal(t, float64(hobbies.ID), response.Data[1]["quiz_id"])
+	}
+
+	// Every question of the quiz needs an answer, with one of its own options.
+	incomplete := quizAnswers(hobbies, hobbyQuestions[:1], 0)
+	rec = quizRequest("POST", "/api/answer/save", user.ID, incomplete)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mixed := quizAnswers(hobbies, hobbyQuestions, 0)
+	mixed["answers"].([]map[string]uint)[1]["option_id"] = musicQuestions[0].Options[0].ID
+	rec = quizRequest("POST", "/api/answer/save", user.ID, mixed)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(hobbies, hobbyQuestions, 1))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(hobbies, hobbyQuestions, 1))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/questions", hobbies.ID), user.ID, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// The other quiz is still open, and is all that is left to answer.
+	rec = quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/questions", music.ID), user.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = quizRequest("GET", "/api/questions", user.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if assert.Len(t, response.Data, 2) {
+		assert.Equal(t, float64(music.ID), response.Data[0]["quiz_id"])
+	}
+
+	rec = quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(music, musicQuestions, 2))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = quizRequest("GET", "/api/questions", user.ID, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = quizRequest("GET", "/api/quizzes/999/questions", user.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	models.DB.Model(&music).Update("status", models.QuizStatusDraft)
+	rec = quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/questions", music.ID), user.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRetakeQuizAfterNewVersion(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	quiz, questions := createQuizWithQuestions(2)
+
+	rec := quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(quiz, questions, 0))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	question, err := models.CreateQuestion(admin.ID, quiz.ID, "Favourite season?", []string{"Summer", "Winter"})
+	assert.NoError(t, err)
+
+	rec = quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/questions", quiz.ID), user.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Answers to the previous version are replaced by the new ones.
+	questions = append(questions, question)
+	rec = quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(quiz, questions, 1))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var userResponses []models.UserResponse
+	models.DB.Where("user_id = ?", user.ID).Order("question_id").Find(&userResponses)
+	if assert.Len(t, userResponses, 3) {
+		assert.Equal(t, questions[0].Options[1].ID, userResponses[0].OptionID)
+	}
+
+	var completion models.QuizCompletion
+	models.DB.Where("user_id = ? AND quiz_id = ?", user.ID, quiz.ID).First(&completion)
+	assert.Equal(t, 2, completion.QuizVersion)
+
+	rec = quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/questions", quiz.ID), user.ID, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestGetResultsForSelectedQuizzes(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	sameHobbies := factory.UserFactory()
+	sameMusic := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&sameHobbies)
+	models.DB.Create(&sameMusic)
+
+	hobbies, hobbyQuestions := createQuizWithQuestions(2)
+	music, musicQuestions := createQuizWithQuestions(2)
+
+	quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(hobbies, hobbyQuestions, 0))
+	quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(music, musicQuestions, 0))
+	quizRequest("POST", "/api/answer/save", sameHobbies.ID, quizAnswers(hobbies, hobbyQuestions, 0))
+	quizRequest("POST", "/api/answer/save", sameHobbies.ID, quizAnswers(music, musicQuestions, 1))
+	quizRequest("POST", "/api/answer/save", sameMusic.ID, quizAnswers(hobbies, hobbyQuestions, 2))
+	quizRequest("POST", "/api/answer/save", sameMusic.ID, quizAnswers(music, musicQuestions, 0))
+
+	matches := func(query string) []uint {
+		rec := quizRequest("GET", fmt.Sprintf("/api/get-results/user/%d%s", user.ID, query), user.ID, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Data []struct {
+				UserID uint `json:"user_id"`
+				Score  int  `json:"score"`
+			} `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+
+		userIDs := make([]uint, 0)
+		for _, match := range response.Data {
+			assert.Equal(t, 2, match.Score)
+			userIDs = append(userIDs, match.UserID)
+		}
+
+		return userIDs
+	}
+
+	assert.Equal(t, []uint{sameHobbies.ID}, matches(fmt.Sprintf("?quiz_id=%d", hobbies.ID)))
+	assert.Equal(t, []uint{sameMusic.ID}, matches(fmt.Sprintf("?quiz_id=%d", music.ID)))
+	assert.ElementsMatch(t, []uint{sameHobbies.ID, sameMusic.ID}, matches(fmt.Sprintf("?quiz_id=%d&quiz_id=%d", hobbies.ID, music.ID)))
+	assert.ElementsMatch(t, []uint{sameHobbies.ID, sameMusic.ID}, matches(""))
+}
+
+func TestGetMyQuizAnswers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	admin := factory.AdminUserFactory()
+	models.DB.Create(&admin)
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	quiz, questions := createQuizWithQuestions(2)
+
+	rec := quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/answers", quiz.ID), user.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(quiz, questions, 2))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	added, err := models.CreateQuestion(admin.ID, quiz.ID, "Favourite season?", []string{"Summer", "Winter"})
+	assert.NoError(t, err)
+
+	rec = quizRequest("GET", fmt.Sprintf("/api/quizzes/%d/answers", quiz.ID), user.ID, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Data struct {
+			QuizID       uint       `json:"quiz_id"`
+			CompletedAt  *time.Time `json:"completed_at"`
+			NextRetakeAt *time.Time `json:"next_retake_at"`
+			Questions    []struct {
+				ID               uint  `json:"id"`
+				SelectedOptionID *uint `json:"selected_option_id"`
+			} `json:"questions"`
+		} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+
+	assert.Equal(t, quiz.ID, response.Data.QuizID)
+	// Answers that were never changed can be changed right away.
+	if assert.NotNil(t, response.Data.CompletedAt) && assert.NotNil(t, response.Data.NextRetakeAt) {
+		assert.WithinDuration(t, *response.Data.CompletedAt, *response.Data.NextRetakeAt, time.Second)
+	}
+
+	if assert.Len(t, response.Data.Questions, 3) {
+		assert.Equal(t, questions[0].Options[2].ID, *response.Data.Questions[0].SelectedOptionID)
+		assert.Equal(t, questions[1].Options[2].ID, *response.Data.Questions[1].SelectedOptionID)
+		assert.Equal(t, added.ID, response.Data.Questions[2].ID)
+		assert.Nil(t, response.Data.Questions[2].SelectedOptionID)
+	}
+}
+
+func TestUpdateQuizAnswers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	os.Unsetenv("QUIZ_RETAKE_COOLDOWN_HOURS")
+	defer os.Unsetenv("QUIZ_RETAKE_COOLDOWN_HOURS")
+
+	user := factory.UserFactory()
+	other := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&other)
+
+	quiz, questions := createQuizWithQuestions(2)
+	path := fmt.Sprintf("/api/quizzes/%d/answers", quiz.ID)
+
+	rec := quizRequest("PUT", path, user.ID, quizAnswers(quiz, questions, 1))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	quizRequest("POST", "/api/answer/save", user.ID, quizAnswers(quiz, questions, 0))
+	quizRequest("POST", "/api/answer/save", other.ID, quizAnswers(quiz, questions, 1))
+
+	score := func() int {
+		rec := quizRequest("GET", fmt.Sprintf("/api/get-results/user/%d", user.ID), user.ID, nil)
+		var response struct {
+			Data []struct {
+				Score int `json:"score"`
+			} `json:"data"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &response)
+
+		if len(response.Data) == 0 {
+			return 0
+		}
+
+		return response.Data[0].Score
+	}
+	assert.Equal(t, 0, score())
+
+	rec = quizRequest("PUT", path, user.ID, quizAnswers(quiz, questions[:1], 1))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// The first change right after taking the quiz is allowed.
+	rec = quizRequest("PUT", path, user.ID, quizAnswers(quiz, questions, 1))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var userResponses []models.UserResponse
+	models.DB.Where("user_id = ?", user.ID).Find(&userResponses)
+	assert.Len(t, userResponses, 2)
+	assert.Equal(t, 2, score())
+
+	completion, err := models.GetQuizCompletion(user.ID, quiz.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, completion.EditedAt)
+
+	rec = quizRequest("PUT", path, user.ID, quizAnswers(quiz, questions, 0))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "next_retake_at")
+
+	models.DB.Model(&models.QuizCompletion{}).Where("user_id = ?", user.ID).
+		Update("edited_at", time.Now().UTC().Add(-models.QuizRetakeCooldown()-time.Minute))
+
+	rec = quizRequest("PUT", path, user.ID, quizAnswers(quiz, questions, 0))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 0, score())
+
+	// The cooldown restarts with every change, unless it is turned off.
+	rec = quizRequest("PUT", path, user.ID, quizAnswers(quiz, questions, 2))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	os.Setenv("QUIZ_RETAKE_COOLDOWN_HOURS", "0")
+	rec = quizRequest("PUT", path, user.ID, quizAnswers(quiz, questions, 2))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 0, score())
+}
@@ -28,7 +28,7 @@ func SetupTestDB() {
 func SetupRoutes() {
 	router = gin.Default()
 	hub := services.NewHub()
-    routes.SetupRoutes(router, hub)
+    routes.SetupRoutes(router, hub, nil)
 }
 
 func SetupRouterWithoutMiddleware() *gin.Engine {
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -9,12 +10,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 	"unifriend-api/handlers"
 	"unifriend-api/middleware"
 	"unifriend-api/models"
+	"unifriend-api/services"
 	"unifriend-api/tests/factory"
 	"unifriend-api/tests/mocks"
 
@@ -809,6 +813,35 @@ func TestVerifyEmailAwsError(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "something went wrong")
 }
 
+func TestVerifyEmailAcceptsUppercaseDomain(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+
+	mockEmailSender := &mocks.MockSesSender{
+		SendVerificationEmailFunc: func(recipient, subject, body string) error {
+			return nil
+		},
+	}
+
+	router := gin.Default()
+
+	router.GET("/api/verify/email/:email", func(c *gin.Context) {
+		handlers.VerifyEmail(c, mockEmailSender)
+	})
+
+	req, _ := http.NewRequest("GET", "/api/verify/email/email@"+strings.ToUpper(emailDomain.Domain), nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
 func TestVerifyEmailWithValidCode(t *testing.T) {
 	SetupTestDB()
 	defer models.TearDownTestDB()
@@ -1505,4 +1538,906 @@ func TestDeleteUser(t *testing.T) {
 	assert.NotNil(t, userAfter.DeletedAt)
 	assert.Equal(t, 0, userAfter.Status)
 
-}
\ No newline at end of file
+}
+func TestChangePasswordSuccess(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	currentToken := factory.GetUserFactoryToken(user.ID)
+	otherDeviceToken := factory.GetUserFactoryToken(user.ID)
+
+	payload := []byte(`{"current_password": "Old@Password1", "password": "New@Password1", "re_password": "New@Password1"}`)
+	req, _ := http.NewRequest("PUT", "/api/users/me/password", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: currentToken, Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, err := models.LoginCheck(user.Email, "New@Password1")
+	assert.NoError(t, err)
+
+	req, _ = http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: currentToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req, _ = http.NewRequest("GET", "/api/connections", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: otherDeviceToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestChangePasswordWrongCurrentPassword(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	payload := []byte(`{"current_password": "Wrong@Password1", "password": "New@Password1", "re_password": "New@Password1"}`)
+	req, _ := http.NewRequest("PUT", "/api/users/me/password", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "current password is incorrect")
+
+	_, err := models.LoginCheck(user.Email, "Old@Password1")
+	assert.NoError(t, err)
+}
+
+func TestChangePasswordInvalidNewPassword(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	payload := []byte(`{"current_password": "Old@Password1", "password": "weak", "re_password": "weak"}`)
+	req, _ := http.NewRequest("PUT", "/api/users/me/password", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestChangeEmailSendsVerificationCode(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+	newEmail := "new@" + emailDomain.Domain
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	var sentTo string
+	mockEmailSender := &mocks.MockSesSender{
+		SendVerificationEmailFunc: func(recipient, subject, body string) error {
+			sentTo = recipient
+			return nil
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.Use(middleware.AuthMiddleware())
+	testRouter.POST("/api/users/me/email", func(c *gin.Context) {
+		handlers.ChangeEmail(c, mockEmailSender)
+	})
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "password": "Old@Password1"}`, newEmail))
+	req, _ := http.NewRequest("POST", "/api/users/me/email", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, newEmail, sentTo)
+	_, err := models.GetValidEmailChange(user.ID, newEmail)
+	assert.NoError(t, err)
+	assert.False(t, models.HasValidVerificationCode(newEmail))
+
+	var unchanged models.User
+	models.DB.First(&unchanged, user.ID)
+	assert.Equal(t, user.Email, unchanged.Email)
+}
+
+func TestChangeEmailDiscardsCodeWhenEmailFails(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+	newEmail := "new@" + emailDomain.Domain
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	mockEmailSender := &mocks.MockSesSender{
+		SendVerificationEmailFunc: func(recipient, subject, body string) error {
+			return errors.New("AWS SES error: Email sending failed")
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.Use(middleware.AuthMiddleware())
+	testRouter.POST("/api/users/me/email", func(c *gin.Context) {
+		handlers.ChangeEmail(c, mockEmailSender)
+	})
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "password": "Old@Password1"}`, newEmail))
+	req, _ := http.NewRequest("POST", "/api/users/me/email", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	_, err := models.GetValidEmailChange(user.ID, newEmail)
+	assert.Error(t, err)
+}
+
+func TestChangeEmailNotAllowedDomain(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Password = "Old@Password1"
+	models.DB.Create(&user)
+
+	mockEmailSender := &mocks.MockSesSender{
+		SendVerificationEmailFunc: func(recipient, subject, body string) error {
+			return nil
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.Use(middleware.AuthMiddleware())
+	testRouter.POST("/api/users/me/email", func(c *gin.Context) {
+		handlers.ChangeEmail(c, mockEmailSender)
+	})
+
+	payload := []byte(`{"email": "new@not-a-university.com", "password": "Old@Password1"}`)
+	req, _ := http.NewRequest("POST", "/api/users/me/email", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Email inválido")
+}
+
+func TestConfirmEmailChangeSuccess(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+	newEmail := "new@" + emailDomain.Domain
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	models.SaveEmailChangeCode(user.ID, newEmail, 123456)
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "verification_code": 123456}`, newEmail))
+	req, _ := http.NewRequest("POST", "/api/users/me/email/confirm", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var updated models.User
+	models.DB.First(&updated, user.ID)
+	assert.Equal(t, newEmail, updated.Email)
+
+	var emailChange models.EmailChange
+	models.DB.Where("user_id = ? AND email = ?", user.ID, newEmail).First(&emailChange)
+	assert.True(t, emailChange.Used)
+}
+
+func TestConfirmEmailChangeWrongCode(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+	newEmail := "new@" + emailDomain.Domain
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	models.SaveEmailChangeCode(user.ID, newEmail, 123456)
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "verification_code": 654321}`, newEmail))
+	req, _ := http.NewRequest("POST", "/api/users/me/email/confirm", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	var unchanged models.User
+	models.DB.First(&unchanged, user.ID)
+	assert.Equal(t, user.Email, unchanged.Email)
+}
+
+func TestConfirmEmailChangeRejectsSignupVerificationCode(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+	newEmail := "new@" + emailDomain.Domain
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	models.SaveVerificationCode(newEmail, 123456)
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "verification_code": 123456}`, newEmail))
+	req, _ := http.NewRequest("POST", "/api/users/me/email/confirm", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var unchanged models.User
+	models.DB.First(&unchanged, user.ID)
+	assert.Equal(t, user.Email, unchanged.Email)
+}
+
+func TestConfirmEmailChangeRejectsOtherUsersCode(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+	newEmail := "new@" + emailDomain.Domain
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+	otherUser := factory.UserFactory()
+	models.DB.Create(&otherUser)
+
+	models.SaveEmailChangeCode(otherUser.ID, newEmail, 123456)
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "verification_code": 123456}`, newEmail))
+	req, _ := http.NewRequest("POST", "/api/users/me/email/confirm", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var unchanged models.User
+	models.DB.First(&unchanged, user.ID)
+	assert.Equal(t, user.Email, unchanged.Email)
+}
+
+func TestConfirmEmailChangeLocksAfterTooManyAttempts(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	emailDomain := factory.EmailDomainsFactory()
+	models.DB.Create(&emailDomain)
+	newEmail := "new@" + emailDomain.Domain
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	models.SaveEmailChangeCode(user.ID, newEmail, 123456)
+
+	for i := 0; i < models.MaxEmailChangeAttempts; i++ {
+		payload := []byte(fmt.Sprintf(`{"email": "%s", "verification_code": 654321}`, newEmail))
+		req, _ := http.NewRequest("POST", "/api/users/me/email/confirm", bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	payload := []byte(fmt.Sprintf(`{"email": "%s", "verification_code": 123456}`, newEmail))
+	req, _ := http.NewRequest("POST", "/api/users/me/email/confirm", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	var unchanged models.User
+	models.DB.First(&unchanged, user.ID)
+	assert.Equal(t, user.Email, unchanged.Email)
+}
+
+func TestGetMyProfile(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	user.Bio = "I like board games"
+	models.DB.Create(&user)
+
+	req, _ := http.NewRequest("GET", "/api/users/me", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	data := response["data"].(map[string]interface{})
+
+	assert.Equal(t, float64(user.ID), data["id"])
+	assert.Equal(t, user.Email, data["email"])
+	assert.Equal(t, user.PhoneNumber, data["phone_number"])
+	assert.Equal(t, "I like board games", data["bio"])
+	assert.Len(t, data["images"], 1)
+}
+
+func TestUpdateMyProfile(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	major := factory.MajorFactory()
+	models.DB.Create(&major)
+
+	payload := []byte(fmt.Sprintf(`{"name": "New Name", "phone_number": "62988887777", "major_id": %d, "bio": "new bio"}`, major.ID))
+	req, _ := http.NewRequest("PATCH", "/api/users/me", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var updated models.User
+	models.DB.First(&updated, user.ID)
+	assert.Equal(t, "New Name", updated.Name)
+	assert.Equal(t, "62988887777", updated.PhoneNumber)
+	assert.Equal(t, major.ID, updated.MajorID)
+	assert.Equal(t, "new bio", updated.Bio)
+	assert.Equal(t, user.Email, updated.Email)
+}
+
+func TestUpdateMyProfileDuplicatedPhoneNumber(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	payload := []byte(fmt.Sprintf(`{"phone_number": "%s"}`, otherUser.PhoneNumber))
+	req, _ := http.NewRequest("PATCH", "/api/users/me", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "phone number is already in use")
+}
+
+func TestUpdateMyProfileBioTooLong(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	payload, _ := json.Marshal(map[string]string{"bio": strings.Repeat("a", 501)})
+	req, _ := http.NewRequest("PATCH", "/api/users/me", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetUserProfileHidesContactForNonConnections(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/%d", otherUser.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	data := response["data"].(map[string]interface{})
+
+	assert.Equal(t, otherUser.Name, data["name"])
+	assert.Equal(t, otherUser.ProfilePictureURL, data["profile_picture_url"])
+	assert.NotNil(t, data["major"])
+	assert.Len(t, data["images"], 1)
+	assert.Equal(t, false, data["has_connection"])
+	assert.NotContains(t, data, "email")
+	assert.NotContains(t, data, "phone_number")
+}
+
+func TestGetUserProfileShowsContactForConnections(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user
+	connection.UserB = otherUser
+	models.DB.Create(&connection)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/%d", otherUser.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	data := response["data"].(map[string]interface{})
+
+	assert.Equal(t, true, data["has_connection"])
+	assert.Equal(t, float64(connection.ID), data["connection_id"])
+	assert.Equal(t, otherUser.Email, data["email"])
+	assert.Equal(t, otherUser.PhoneNumber, data["phone_number"])
+}
+
+func TestGetUserProfileBlockedUser(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	models.BlockUser(otherUser.ID, user.ID)
+
+	for _, pair := range [][2]uint{{user.ID, otherUser.ID}, {otherUser.ID, user.ID}} {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/%d", pair[1]), nil)
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(pair[0]), Path: "/"})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestGetUserProfileDeletedUser(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	otherUser.Status = 0
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+	models.DB.Model(&otherUser).UpdateColumn("status", 0)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/%d", otherUser.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDeleteUserErasesPersonalData(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user
+	connection.UserB = otherUser
+	models.DB.Create(&connection)
+
+	sent := models.Message{ConnectionID: connection.ID, SenderID: user.ID, ReceiverID: otherUser.ID, Content: "secret"}
+	received := models.Message{ConnectionID: connection.ID, SenderID: otherUser.ID, ReceiverID: user.ID, Content: "hello"}
+	models.DB.Create(&sent)
+	models.DB.Create(&received)
+
+	userResponse := factory.UserResponseFactory()
+	userResponse.User = user
+	models.DB.Create(&userResponse)
+
+	pendingRequest := factory.ConnectionRequestFactory()
+	pendingRequest.RequestingUser = user
+	pendingRequest.RequestedUser = factory.UserFactory()
+	models.DB.Create(&pendingRequest)
+
+	var deletedFiles []string
+	mockUploader := &mocks.MockS3Uploader{
+		DeleteImageFunc: func(fileName string) error {
+			deletedFiles = append(deletedFiles, fileName)
+			return nil
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.Use(middleware.AuthMiddleware())
+	testRouter.DELETE("/api/users/me", func(c *gin.Context) {
+		handlers.DeleteUserAccount(c, mockUploader)
+	})
+
+	authToken := factory.GetUserFactoryToken(user.ID)
+	req, _ := http.NewRequest("DELETE", "/api/users/me", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: authToken, Path: "/"})
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Len(t, deletedFiles, 2)
+
+	var imageCount, responseCount, requestCount int64
+	models.DB.Model(&models.UsersImages{}).Where("user_id = ?", user.ID).Count(&imageCount)
+	models.DB.Model(&models.UserResponse{}).Where("user_id = ?", user.ID).Count(&responseCount)
+	models.DB.Model(&models.ConnectionRequest{}).Where("id = ?", pendingRequest.ID).Count(&requestCount)
+	assert.Equal(t, int64(0), imageCount)
+	assert.Equal(t, int64(0), responseCount)
+	assert.Equal(t, int64(0), requestCount)
+
+	models.DB.First(&sent, sent.ID)
+	models.DB.First(&received, received.ID)
+	assert.Equal(t, "", sent.Content)
+	assert.Equal(t, "hello", received.Content)
+
+	var deletedUser models.User
+	models.DB.First(&deletedUser, user.ID)
+	assert.Equal(t, 0, deletedUser.Status)
+	if assert.NotNil(t, deletedUser.DeletedAt) {
+		assert.WithinDuration(t, time.Now().UTC(), *deletedUser.DeletedAt, time.Minute)
+	}
+	assert.Equal(t, "", deletedUser.ProfilePictureURL)
+
+	req, _ = http.NewRequest("DELETE", "/api/users/me", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: authToken, Path: "/"})
+	rec = httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	expired := factory.UserFactory()
+	recent := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&expired)
+	models.DB.Create(&recent)
+	models.DB.Create(&otherUser)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = expired
+	connection.UserB = otherUser
+	models.DB.Create(&connection)
+	models.DB.Create(&models.Message{ConnectionID: connection.ID, SenderID: otherUser.ID, ReceiverID: expired.ID, Content: "hi"})
+
+	longAgo := time.Now().UTC().Add(-48 * time.Hour)
+	justNow := time.Now().UTC()
+	models.DB.Model(&expired).Updates(map[string]interface{}{"Status": 0, "DeletedAt": longAgo})
+	models.DB.Model(&recent).Updates(map[string]interface{}{"Status": 0, "DeletedAt": justNow})
+
+	purged, err := models.PurgeDeletedUsers(24 * time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	var count int64
+	models.DB.Model(&models.User{}).Where("id = ?", expired.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	models.DB.Model(&models.User{}).Where("id = ?", recent.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	models.DB.Model(&models.Connection{}).Where("id = ?", connection.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	models.DB.Model(&models.Message{}).Where("connection_id = ?", connection.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+	models.DB.Model(&models.UsersImages{}).Where("user_id = ?", expired.ID).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRequestDataExportGeneratesArchive(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	os.Setenv("DATA_EXPORT_DIR", t.TempDir())
+	defer os.Unsetenv("DATA_EXPORT_DIR")
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	connection := factory.ConnectionFactory()
+	connection.UserA = user
+	connection.UserB = otherUser
+	models.DB.Create(&connection)
+	models.DB.Create(&models.Message{ConnectionID: connection.ID, SenderID: user.ID, ReceiverID: otherUser.ID, Content: "exported message"})
+
+	exporter := services.NewDataExporter()
+	mockQueue := &mocks.MockDataExportQueue{
+		EnqueueFunc: func(dataExport *models.DataExport) error {
+			return exporter.Generate(dataExport)
+		},
+	}
+
+	testRouter := gin.Default()
+	testRouter.Use(middleware.AuthMiddleware())
+	testRouter.POST("/api/users/me/export", func(c *gin.Context) {
+		handlers.RequestDataExport(c, mockQueue)
+	})
+	testRouter.GET("/api/users/me/export/:export_id", handlers.GetDataExport)
+	testRouter.GET("/api/users/me/export/:export_id/download", handlers.DownloadDataExport)
+
+	authCookie := &http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"}
+
+	req, _ := http.NewRequest("POST", "/api/users/me/export", nil)
+	req.AddCookie(authCookie)
+	rec := httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	var dataExport models.DataExport
+	models.DB.Where("user_id = ?", user.ID).First(&dataExport)
+	assert.Equal(t, models.ExportReady, dataExport.Status)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/users/me/export/%d/download", dataExport.ID), nil)
+	req.AddCookie(authCookie)
+	rec = httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var archive models.UserDataArchive
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &archive))
+	assert.Equal(t, user.Email, archive.User.Email)
+	assert.Len(t, archive.Messages, 1)
+	assert.Equal(t, "exported message", archive.Messages[0].Content)
+	assert.Len(t, archive.Connections, 1)
+	assert.Len(t, archive.ImageURLs, 2)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/users/me/export/%d/download?format=zip", dataExport.ID), nil)
+	req.AddCookie(authCookie)
+	rec = httptest.NewRecorder()
+	testRouter.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	zipReader, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, zipReader.File, 1)
+}
+
+func TestDownloadDataExportOfAnotherUser(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	otherUser := factory.UserFactory()
+	models.DB.Create(&user)
+	models.DB.Create(&otherUser)
+
+	dataExport, _ := models.CreateDataExport(otherUser.ID)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/me/export/%d/download", dataExport.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDownloadDataExportNotReady(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	dataExport, _ := models.CreateDataExport(user.ID)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/me/export/%d/download", dataExport.ID), nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestStaleDataExportDoesNotBlockNewExport(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	stale, _ := models.CreateDataExport(user.ID)
+	models.DB.Model(stale).Update("created_at", time.Now().Add(-2*time.Hour))
+	assert.False(t, models.HasDataExportInProgress(user.ID))
+
+	recent, _ := models.CreateDataExport(user.ID)
+	assert.True(t, models.HasDataExportInProgress(user.ID))
+
+	failed, err := models.FailStaleDataExports()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+
+	models.DB.First(stale, stale.ID)
+	models.DB.First(recent, recent.ID)
+	assert.Equal(t, models.ExportFailed, stale.Status)
+	assert.Equal(t, models.ExportPending, recent.Status)
+}
+
+func TestInterruptedDataExportsFailOnStartup(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	interrupted, _ := models.CreateDataExport(user.ID)
+	models.DB.Model(interrupted).Update("created_at", time.Now().Add(-time.Minute))
+	assert.True(t, models.HasDataExportInProgress(user.ID))
+
+	failed, err := models.FailInterruptedDataExports(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+
+	models.DB.First(interrupted, interrupted.ID)
+	assert.Equal(t, models.ExportFailed, interrupted.Status)
+	assert.False(t, models.HasDataExportInProgress(user.ID))
+}
+
+func TestDownloadDataExportWithMissingFile(t *testing.T) {
+	SetupTestDB()
+	SetupRoutes()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	dataExport, _ := models.CreateDataExport(user.ID)
+	dataExport.MarkReady(filepath.Join(t.TempDir(), "missing.json"), time.Hour)
+
+	for _, query := range []string{"", "?format=zip"} {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/users/me/export/%d/download%s", dataExport.ID, query), nil)
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: factory.GetUserFactoryToken(user.ID), Path: "/"})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusGone, rec.Code)
+	}
+}
+
+func TestDataExporterEnqueueAfterStop(t *testing.T) {
+	exporter := services.NewDataExporter()
+	exporter.Stop()
+	exporter.Stop()
+
+	assert.NotPanics(t, func() {
+		assert.Error(t, exporter.Enqueue(&models.DataExport{}))
+	})
+}
+
+func TestRemoveExpiredDataExportFiles(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	dir := t.TempDir()
+	expiredPath := filepath.Join(dir, "expired.json")
+	validPath := filepath.Join(dir, "valid.json")
+	os.WriteFile(expiredPath, []byte("{}"), 0o600)
+	os.WriteFile(validPath, []byte("{}"), 0o600)
+
+	expired, _ := models.CreateDataExport(user.ID)
+	expired.MarkReady(expiredPath, -time.Hour)
+	valid, _ := models.CreateDataExport(user.ID)
+	valid.MarkReady(validPath, time.Hour)
+
+	removed, err := models.RemoveExpiredDataExportFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	_, err = os.Stat(expiredPath)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(validPath)
+	assert.NoError(t, err)
+
+	models.DB.First(expired, expired.ID)
+	assert.Empty(t, expired.FilePath)
+}
+
+func TestPurgeDeletedUsersRemovesDataExportFiles(t *testing.T) {
+	SetupTestDB()
+	defer models.TearDownTestDB()
+
+	user := factory.UserFactory()
+	models.DB.Create(&user)
+
+	exportPath := filepath.Join(t.TempDir(), "export.json")
+	os.WriteFile(exportPath, []byte("{}"), 0o600)
+
+	dataExport, _ := models.CreateDataExport(user.ID)
+	dataExport.MarkReady(exportPath, time.Hour)
+
+	models.DB.Model(&user).Updates(map[string]interface{}{"Status": 0, "DeletedAt": time.Now().Add(-48 * time.Hour)})
+
+	purged, err := models.PurgeDeletedUsers(24 * time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, err = os.Stat(exportPath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,36 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"time"
+)
+
+const defaultRefreshTokenHourLifespan = 720
+
+func GenerateRefreshToken() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func RefreshTokenLifespan() time.Duration {
+	lifespan, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_HOUR_LIFESPAN"))
+	if err != nil || lifespan <= 0 {
+		lifespan = defaultRefreshTokenHourLifespan
+	}
+
+	return time.Duration(lifespan) * time.Hour
+}
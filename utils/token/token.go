@@ -11,24 +11,37 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func GenerateToken(user_id uint) (string, error) {
+const defaultTokenMinuteLifespan = 15
 
-	token_lifespan, err := strconv.Atoi(os.Getenv("TOKEN_HOUR_LIFESPAN"))
-
-	if err != nil {
-		return "", err
-	}
+func GenerateToken(user_id uint, session_id uint) (string, error) {
 
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["user_id"] = user_id
-	claims["exp"] = time.Now().Add(time.Hour * time.Duration(token_lifespan)).Unix()
+	claims["session_id"] = session_id
+	claims["exp"] = time.Now().Add(TokenLifespan()).Unix()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return token.SignedString([]byte(os.Getenv("API_SECRET")))
 
 }
 
+// TokenLifespan reads TOKEN_MINUTE_LIFESPAN. Deployments that still set the
+// former TOKEN_HOUR_LIFESPAN keep their lifespan until they switch over.
+func TokenLifespan() time.Duration {
+	token_lifespan, err := strconv.Atoi(os.Getenv("TOKEN_MINUTE_LIFESPAN"))
+	if err == nil && token_lifespan > 0 {
+		return time.Minute * time.Duration(token_lifespan)
+	}
+
+	token_lifespan, err = strconv.Atoi(os.Getenv("TOKEN_HOUR_LIFESPAN"))
+	if err == nil && token_lifespan > 0 {
+		return time.Hour * time.Duration(token_lifespan)
+	}
+
+	return time.Minute * defaultTokenMinuteLifespan
+}
+
 func TokenValid(c *gin.Context) error {
 	tokenString := ExtractToken(c)
 	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {